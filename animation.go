@@ -0,0 +1,165 @@
+package main
+
+import "math"
+
+// The easing function used to animate an operation
+type Easing int
+
+const (
+	LINEAR Easing = iota
+	EASEINOUT
+	SPRING // Overshoots the target slightly, then settles back onto it
+)
+
+const (
+	// Retargeting an animation with less than this much of its eased progress left starts the easing again
+	maxRetargetProgress = 0.9
+)
+
+// A source of frame times for the animations, in milliseconds
+type frameClock interface {
+	Now() float64
+}
+
+// A frame clock driven by the timestamps the browser passes to requestAnimationFrame callbacks
+type browserClock struct {
+	t float64
+}
+
+// A frame clock which only moves when told to.  Useful for deterministic animation, as the frame times don't depend
+// upon the browser or the scheduler
+type manualClock struct {
+	t float64
+}
+
+// An operation being animated
+type animation struct {
+	op     Operation
+	before matrix  // The view matrix before the operation, kept for the history when the animation is retargeted
+	start  matrix  // The view matrix when the animation started
+	end    matrix  // The view matrix when the animation finishes
+	rel    matrix  // The transformation taking the start view matrix to the end one
+	begin  float64 // The frame time the animation started, or -1 if it hasn't been drawn yet
+	from   float64 // The eased progress when the animation was last retargeted, which the rest of it carries on from
+	left   float64 // For rotations, the degrees left to turn when the animation was last retargeted
+}
+
+var (
+	// The clock the animations are run against.  Protected by sceneLock, as it's swapped out while frames are captured
+	clock frameClock = &browserClock{}
+
+	// The animation in progress, if any
+	anim *animation
+)
+
+// Returns the time of the most recent frame
+func (c *browserClock) Now() float64 {
+	return c.t
+}
+
+// Returns the progress of an animation after applying the easing function.  Progress is from 0 to 1, and the eased
+// result is always exactly 0 at the start and exactly 1 at the end
+func ease(e Easing, p float64) float64 {
+	if p <= 0 {
+		return 0
+	}
+	if p >= 1 {
+		return 1
+	}
+	switch e {
+	case EASEINOUT:
+		return p * p * (3 - 2*p)
+	case SPRING:
+		return 1 - math.Exp(-6*p)*math.Cos(3*math.Pi*p)*(1-p)
+	default:
+		return p
+	}
+}
+
+// Returns how far the animation has got, since it was last retargeted, at the given frame time.  This is the eased
+// progress, so it's 0 when the animation was retargeted and 1 at the end, but a spring can overshoot
+func (a *animation) progress(now float64) float64 {
+	if a.begin < 0 {
+		return 0
+	}
+	p := float64(1)
+	if a.op.T > 0 {
+		p = (now - a.begin) / float64(a.op.T)
+	}
+	return (ease(a.op.E, p) - a.from) / (1 - a.from)
+}
+
+// Moves the manual clock forward by the given number of milliseconds
+func (c *manualClock) Advance(ms float64) {
+	c.t += ms
+}
+
+// Returns the time of the current frame
+func (c *manualClock) Now() float64 {
+	return c.t
+}
+
+// Starts animating an operation from the current view.  The scene lock must be held by the caller
+func startAnimation(op Operation) {
+	anim = &animation{op: op, before: viewMatrix, begin: -1, left: rotationAngle(op)}
+	retarget(operationTarget(op, viewMatrix))
+	opText = operationText(op)
+}
+
+// Points the animation in progress at a new end view matrix, carrying on from the current view.  The animation keeps
+// its start time, so it carries on at the same speed rather than easing in again, with the rest of the easing spread
+// over the new change of view.  Close to the end there's too little of the easing left to spread, and a spring would
+// have its overshoot magnified, so those start the easing again instead.  The scene lock must be held by the caller
+func retarget(end matrix) {
+	e := anim.progress(clock.Now())*(1-anim.from) + anim.from
+	if anim.begin >= 0 && e < maxRetargetProgress && anim.op.E != SPRING {
+		anim.from = e
+	} else {
+		anim.begin, anim.from = -1, 0
+	}
+	anim.start = viewMatrix
+	anim.end = end
+	anim.rel = matrixMult(end, invertMatrix(viewMatrix))
+}
+
+// Updates the view matrix to match the progress of the animation in progress (if any) at the given frame time.  When
+// the animation finishes, the next pending operation is started
+func stepAnimation(now float64) {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	if anim == nil {
+		if !nextOperation() {
+			return
+		}
+	}
+	defer publishScene()
+	if anim.begin < 0 {
+		anim.begin = now
+	}
+
+	// Work out how far through the animation we are
+	p := float64(1)
+	if anim.op.T > 0 {
+		p = (now - anim.begin) / float64(anim.op.T)
+	}
+	if p >= 1 {
+		viewMatrix = anim.end
+		finishOperation()
+		return
+	}
+	viewMatrix = matrixMult(interpolateMatrix(anim.rel, anim.progress(now)), anim.start)
+}
+
+// Moves the browser frame clock on to the given frame time, and returns the time to step the animations to.  Returns
+// false while frames are being captured, as they're stepped by the capture's own clock instead
+func tickBrowserClock(t float64) (float64, bool) {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	if capturing {
+		return 0, false
+	}
+	if c, ok := clock.(*browserClock); ok {
+		c.t = t
+	}
+	return clock.Now(), true
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// Returns true if two matrices are the same, to within rounding error
+func matrixNear(a matrix, b matrix) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestEase(t *testing.T) {
+	tests := []struct {
+		name     string
+		e        Easing
+		monotone bool
+	}{
+		{"linear", LINEAR, true},
+		{"ease in out", EASEINOUT, true},
+		{"spring", SPRING, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// The endpoints are exact, and progress outside of 0 to 1 is clamped to them
+			for _, p := range []float64{-1, 0} {
+				if v := ease(tc.e, p); v != 0 {
+					t.Errorf("ease(%v) = %v, want 0", p, v)
+				}
+			}
+			for _, p := range []float64{1, 2} {
+				if v := ease(tc.e, p); v != 1 {
+					t.Errorf("ease(%v) = %v, want 1", p, v)
+				}
+			}
+
+			// Only the spring is allowed to go backwards, and it has to overshoot the target on the way
+			prev, max := 0.0, 0.0
+			for i := 1; i <= 100; i++ {
+				v := ease(tc.e, float64(i)/100)
+				if tc.monotone && v < prev {
+					t.Errorf("ease(%v) = %v, which is less than the %v before it", float64(i)/100, v, prev)
+				}
+				prev, max = v, math.Max(max, v)
+			}
+			if !tc.monotone && max <= 1 {
+				t.Errorf("spring never overshoots the target")
+			}
+		})
+	}
+}
+
+func TestInterpolateMatrix(t *testing.T) {
+	tests := []struct {
+		name string
+		m    matrix
+		p    float64
+		want matrix
+	}{
+		{"start", rotateAroundZ(identityMatrix, 90), 0, identityMatrix},
+		{"end", rotateAroundZ(identityMatrix, 90), 1, rotateAroundZ(identityMatrix, 90)},
+		{"half a rotation", rotateAroundZ(identityMatrix, 90), 0.5, rotateAroundZ(identityMatrix, 45)},
+		{"a quarter of a rotation", rotateAroundX(identityMatrix, 60), 0.25, rotateAroundX(identityMatrix, 15)},
+		{"half a translation", translate(identityMatrix, 4, -2, 6), 0.5, translate(identityMatrix, 2, -1, 3)},
+		{"half a zoom", scale(identityMatrix, 4, 4, 4), 0.5, scale(identityMatrix, 2, 2, 2)},
+
+		// Rotating around a pivot point keeps the pivot still the whole way through
+		{"rotation around a point", aroundPoint(rotateAroundZ(identityMatrix, 90), Point{X: 1, Y: 1}), 0.5,
+			aroundPoint(rotateAroundZ(identityMatrix, 45), Point{X: 1, Y: 1})},
+
+		// So does zooming in on a point
+		{"zoom around a point", aroundPoint(scale(identityMatrix, 4, 4, 4), Point{X: 2, Y: -3}), 0.5,
+			aroundPoint(scale(identityMatrix, 2, 2, 2), Point{X: 2, Y: -3})},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := interpolateMatrix(tc.m, tc.p); !matrixNear(got, tc.want) {
+				t.Errorf("interpolateMatrix(%v, %v) = %v, want %v", tc.m, tc.p, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStepAnimation(t *testing.T) {
+	tests := []struct {
+		name  string
+		op    Operation
+		steps []float64 // Milliseconds to move the clock on by before each step
+		want  []matrix  // The view matrix after each step
+	}{
+		{
+			name:  "linear translation",
+			op:    Operation{Op: TRANSLATE, T: 1000, E: LINEAR, X: 10},
+			steps: []float64{0, 500, 500},
+			want: []matrix{
+				identityMatrix,
+				translate(identityMatrix, 5, 0, 0),
+				translate(identityMatrix, 10, 0, 0),
+			},
+		},
+		{
+			name:  "eased translation",
+			op:    Operation{Op: TRANSLATE, T: 1000, E: EASEINOUT, Y: 8},
+			steps: []float64{0, 250, 250, 1000},
+			want: []matrix{
+				identityMatrix,
+				translate(identityMatrix, 0, 8*ease(EASEINOUT, 0.25), 0),
+				translate(identityMatrix, 0, 4, 0),
+				translate(identityMatrix, 0, 8, 0),
+			},
+		},
+		{
+			name:  "instant",
+			op:    Operation{Op: TRANSLATE, T: 0, Z: 3},
+			steps: []float64{0},
+			want:  []matrix{translate(identityMatrix, 0, 0, 3)},
+		},
+	}
+	saved := clock
+	defer func() { clock = saved }()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &manualClock{}
+			clock = c
+			sceneLock.Lock()
+			viewMatrix = identityMatrix
+			anim = nil
+			pending = []Operation{tc.op}
+			sceneLock.Unlock()
+
+			for i, ms := range tc.steps {
+				c.Advance(ms)
+				stepAnimation(c.Now())
+				if v := currentScene().view; !matrixNear(v, tc.want[i]) {
+					t.Errorf("after %vms, view = %v, want %v", c.Now(), v, tc.want[i])
+				}
+			}
+			sceneLock.Lock()
+			defer sceneLock.Unlock()
+			if anim != nil || len(pending) != 0 {
+				t.Errorf("animation still in progress after %vms", c.Now())
+			}
+		})
+	}
+}
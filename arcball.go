@@ -0,0 +1,96 @@
+package main
+
+import "math"
+
+const (
+	// The fraction of the spin speed kept after each 1/60th of a second, once the mouse button is released
+	spinDecay = 0.95
+
+	// Spinning stops once it slows below this many radians per millisecond
+	spinMinSpeed = 0.00001
+
+	// Only keep spinning after release if the mouse was still moving this recently (in milliseconds)
+	spinMaxIdle = 50
+)
+
+var (
+	// The arcball drag state.  This and the inertia state are protected by sceneLock
+	arcballActive   bool
+	arcballStart    vector     // The point on the arcball where the drag started
+	arcballRotation quaternion // The rotation applied so far during the current drag
+	arcballLastTime float64    // Timestamp (ms) of the last drag movement
+
+	// The inertia state, used to keep the model spinning after the mouse button is released
+	spinAxis  vector
+	spinSpeed float64 // Radians per millisecond
+)
+
+// Starts an arcball drag at the given screen co-ordinates.  Any inertia from a previous drag is stopped.  The scene
+// lock must be held by the caller
+func arcballBegin(x float64, y float64, timeStamp float64) {
+	arcballActive = true
+	arcballStart = arcballVector(x, y)
+	arcballRotation = quaternion{W: 1}
+	arcballLastTime = timeStamp
+	spinSpeed = 0
+}
+
+// Continues an arcball drag to the given screen co-ordinates, returning the rotation matrix to apply since the last
+// movement.  The scene lock must be held by the caller
+func arcballDrag(x float64, y float64, timeStamp float64) matrix {
+	q := quatBetween(arcballStart, arcballVector(x, y))
+	delta := q.mult(arcballRotation.conj()).normalise()
+	arcballRotation = q
+
+	// Track the speed of the drag, for the inertia after release
+	axis, angle := delta.axisAngle()
+	if dt := timeStamp - arcballLastTime; dt > 0 {
+		spinAxis = axis
+		spinSpeed = angle / dt
+	}
+	arcballLastTime = timeStamp
+	return delta.matrix()
+}
+
+// Finishes an arcball drag.  If the mouse was still moving when the button was released, the model keeps spinning.
+// The scene lock must be held by the caller
+func arcballEnd(timeStamp float64) {
+	arcballActive = false
+	if timeStamp-arcballLastTime > spinMaxIdle {
+		spinSpeed = 0
+	}
+}
+
+// Returns the rotation matrix for the inertia spin over the given number of milliseconds, and slows the spin down.
+// Returns nil when there's no spin in progress.  Once the spin has slowed down enough, its speed is set to zero, so
+// anything waiting for the spin to finish can tell it has.  The scene lock must be held by the caller
+func arcballSpin(dt float64) matrix {
+	if arcballActive || spinSpeed == 0 || dt <= 0 {
+		return nil
+	}
+	if spinSpeed < spinMinSpeed {
+		spinSpeed = 0
+		return nil
+	}
+	m := quatFromAxisAngle(spinAxis, spinSpeed*dt).matrix()
+	spinSpeed *= math.Pow(spinDecay, dt/(1000.0/60))
+	return m
+}
+
+// Returns the point on the arcball under the given screen co-ordinates.  The arcball is centered on the graph area, and
+// points outside of it are mapped to its edge
+func arcballVector(x float64, y float64) vector {
+	radius := math.Min(graphWidth, graphHeight) / 2
+	v := vector{
+		X: (x - graphWidth/2) / radius,
+		Y: ((y - graphHeight/2) / radius) * -1,
+	}
+	d := v.X*v.X + v.Y*v.Y
+	if d > 1 {
+		l := math.Sqrt(d)
+		v.X, v.Y = v.X/l, v.Y/l
+	} else {
+		v.Z = math.Sqrt(1 - d)
+	}
+	return v
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// The range of world space covered by the graph objects
+type domain struct {
+	MinX, MaxX float64
+	MinY, MaxY float64
+	MinZ, MaxZ float64
+}
+
+const (
+	// The approximate number of tick marks to place along the longest axis
+	targetTicks = 10
+
+	// The most tick marks placed along each axis.  As the tick spacing shrinks when zooming in, only the ticks within
+	// this many of the part of the axis in view are generated, rather than all of them along the whole axis.  That's
+	// about four screens' worth, so there are still ticks in view after panning around a bit
+	maxAxisTicks = 40
+)
+
+// Returns, for each axis, the position along it which is closest to the centre of the graph area for the given view
+// matrix.  Axes pointing straight out of the screen give their origin, as every position along them is as close
+func axisCentres(m matrix) (c Point) {
+	closest := func(ax float64, ay float64) float64 {
+		if d := (ax * ax) + (ay * ay); d > 1e-12 {
+			return -((ax * m[3]) + (ay * m[7])) / d
+		}
+		return 0
+	}
+	return Point{X: closest(m[0], m[4]), Y: closest(m[1], m[5]), Z: closest(m[2], m[6])}
+}
+
+// Returns the extent of each axis for the given domain.  Each axis passes through the origin, and as the graphs are
+// flat on the XY plane the Z axis is given the same extent as the X axis
+func axisExtents(d domain) (ext domain) {
+	ext.MinX, ext.MaxX = math.Min(d.MinX, 0), math.Max(d.MaxX, 0)
+	ext.MinY, ext.MaxY = math.Min(d.MinY, 0), math.Max(d.MaxY, 0)
+	ext.MinZ, ext.MaxZ = math.Min(d.MinZ, 0), math.Max(d.MaxZ, 0)
+	if ext.MinZ == ext.MaxZ {
+		ext.MinZ, ext.MaxZ = ext.MinX, ext.MaxX
+	}
+	return
+}
+
+// Generates the X, Y, and Z axes for the given domain, with tick marks and numeric labels at "nice" intervals.  The
+// zoom level is the current scale factor of the world space, and is used to space the ticks more closely as the user
+// zooms in.  The ticks are limited to those near the given point, which is usually the part of each axis in view
+func generateAxes(d domain, zoom float64, c Point) Object {
+	ext := axisExtents(d)
+	minX, maxX := ext.MinX, ext.MaxX
+	minY, maxY := ext.MinY, ext.MaxY
+	minZ, maxZ := ext.MinZ, ext.MaxZ
+	spacing := tickSpacing(ext, zoom)
+	tickLen := spacing / 5
+	labelGap := spacing / 2
+
+	// Extend each axis out to the next tick mark past the data
+	minX, maxX = math.Floor(minX/spacing)*spacing, math.Ceil(maxX/spacing)*spacing
+	minY, maxY = math.Floor(minY/spacing)*spacing, math.Ceil(maxY/spacing)*spacing
+	minZ, maxZ = math.Floor(minZ/spacing)*spacing, math.Ceil(maxZ/spacing)*spacing
+
+	ax := Object{C: "black", Name: "axes"}
+	addLine := func(p1, p2 Point) {
+		n := len(ax.P)
+		ax.P = append(ax.P, p1, p2)
+		ax.E = append(ax.E, Edge{n, n + 1})
+	}
+	addLabel := func(p Point, label string, align string) {
+		p.Label = label
+		p.LabelAlign = align
+		ax.P = append(ax.P, p)
+	}
+
+	// The axis lines themselves
+	addLine(Point{X: minX}, Point{X: maxX})
+	addLine(Point{Y: minY}, Point{Y: maxY})
+	addLine(Point{Z: minZ}, Point{Z: maxZ})
+	addLabel(Point{X: maxX + labelGap}, "X", "left")
+	addLabel(Point{Y: maxY + labelGap}, "Y", "center")
+	addLabel(Point{Z: maxZ + labelGap}, "Z", "left")
+
+	// Tick marks and numeric labels.  The origin is skipped, as it would otherwise get a label from every axis
+	minX, maxX = tickWindow(minX, maxX, spacing, c.X)
+	minY, maxY = tickWindow(minY, maxY, spacing, c.Y)
+	minZ, maxZ = tickWindow(minZ, maxZ, spacing, c.Z)
+	for _, v := range ticks(minX, maxX, spacing) {
+		addLine(Point{X: v, Y: -tickLen}, Point{X: v, Y: tickLen})
+		addLabel(Point{X: v, Y: -tickLen - labelGap}, tickLabel(v, spacing), "center")
+	}
+	for _, v := range ticks(minY, maxY, spacing) {
+		addLine(Point{X: -tickLen, Y: v}, Point{X: tickLen, Y: v})
+		addLabel(Point{X: -tickLen - labelGap/2, Y: v}, tickLabel(v, spacing), "right")
+	}
+	for _, v := range ticks(minZ, maxZ, spacing) {
+		addLine(Point{X: -tickLen, Z: v}, Point{X: tickLen, Z: v})
+		addLabel(Point{X: tickLen + labelGap/2, Z: v}, tickLabel(v, spacing), "left")
+	}
+	return ax
+}
+
+// Returns a "nice" number approximately equal to x.  The number is rounded if round is true, otherwise it's the
+// ceiling.  Nice numbers are 1, 2, and 5 times a power of ten.  From Paul Heckbert's "Nice numbers for graph labels",
+// in Graphics Gems (1990)
+func niceNum(x float64, round bool) float64 {
+	exp := math.Floor(math.Log10(x))
+	f := x / math.Pow(10, exp)
+	var nf float64
+	if round {
+		switch {
+		case f < 1.5:
+			nf = 1
+		case f < 3:
+			nf = 2
+		case f < 7:
+			nf = 5
+		default:
+			nf = 10
+		}
+	} else {
+		switch {
+		case f <= 1:
+			nf = 1
+		case f <= 2:
+			nf = 2
+		case f <= 5:
+			nf = 5
+		default:
+			nf = 10
+		}
+	}
+	return nf * math.Pow(10, exp)
+}
+
+// Returns the smallest domain containing all the points of the given objects
+func objectDomain(objs []Object) (d domain) {
+	first := true
+	for _, o := range objs {
+		for _, p := range o.P {
+			if first {
+				d = domain{MinX: p.X, MaxX: p.X, MinY: p.Y, MaxY: p.Y, MinZ: p.Z, MaxZ: p.Z}
+				first = false
+				continue
+			}
+			d.MinX, d.MaxX = math.Min(d.MinX, p.X), math.Max(d.MaxX, p.X)
+			d.MinY, d.MaxY = math.Min(d.MinY, p.Y), math.Max(d.MaxY, p.Y)
+			d.MinZ, d.MaxZ = math.Min(d.MinZ, p.Z), math.Max(d.MaxZ, p.Z)
+		}
+	}
+	return
+}
+
+// Returns the spacing between tick marks for the given axis extents, based upon the longest axis and the current zoom
+// level
+func tickSpacing(ext domain, zoom float64) float64 {
+	longest := math.Max(ext.MaxX-ext.MinX, math.Max(ext.MaxY-ext.MinY, ext.MaxZ-ext.MinZ))
+	if longest == 0 {
+		longest = 1
+	}
+	if zoom <= 0 {
+		zoom = 1
+	}
+	return niceNum(longest/zoom/targetTicks, true)
+}
+
+// Returns the part of an axis from min to max which has ticks generated for it, when they're at the given spacing.
+// Long axes are cut down to maxAxisTicks ticks, centred on c where possible
+func tickWindow(min float64, max float64, spacing float64, c float64) (float64, float64) {
+	half := spacing * maxAxisTicks / 2
+	if max-min <= 2*half {
+		return min, max
+	}
+	c = math.Max(min+half, math.Min(max-half, c))
+	return math.Round((c-half)/spacing) * spacing, math.Round((c+half)/spacing) * spacing
+}
+
+// Returns the formatted label for a tick mark, using only as many decimal places as the tick spacing needs
+func tickLabel(v float64, spacing float64) string {
+	places := int(math.Max(0, -math.Floor(math.Log10(spacing))))
+	return fmt.Sprintf("%.*f", places, v)
+}
+
+// Returns the tick positions between min and max (inclusive) at the given spacing, excluding zero
+func ticks(min float64, max float64, spacing float64) (t []float64) {
+	for i := math.Round(min / spacing); i <= math.Round(max/spacing); i++ {
+		if i != 0 {
+			t = append(t, i*spacing)
+		}
+	}
+	return
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAxisCentres(t *testing.T) {
+	tests := []struct {
+		name string
+		m    matrix
+		want Point
+	}{
+		{"identity", identityMatrix, Point{}},
+		{"panned", translate(identityMatrix, -3, 2, 0), Point{X: 3, Y: -2}},
+		{"zoomed and panned", translate(scale(identityMatrix, 4, 4, 4), -8, 4, 0), Point{X: 2, Y: -1}},
+
+		// The Z axis points straight out of the screen in the top view, so it's centred on its origin
+		{"top view", translate(presetRotation(TOPVIEW), 1, 1, 0), Point{X: -1, Y: -1}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := axisCentres(tc.m)
+			if math.Abs(c.X-tc.want.X) > 1e-9 || math.Abs(c.Y-tc.want.Y) > 1e-9 || math.Abs(c.Z-tc.want.Z) > 1e-9 {
+				t.Errorf("axisCentres() = %+v, want %+v", c, tc.want)
+			}
+		})
+	}
+}
+
+func TestTicksLimited(t *testing.T) {
+	d := domain{MinX: -10, MaxX: 10, MinY: -100, MaxY: 100}
+	tests := []struct {
+		name string
+		zoom float64
+		c    Point
+	}{
+		{"not zoomed", 1, Point{}},
+		{"zoomed in", 1000, Point{X: 3, Y: 50}},
+		{"zoomed in near the end", 1000, Point{X: 9.999, Y: -100}},
+		{"zoomed in past the end", 1000, Point{X: 50, Y: 500}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			spacing := tickSpacing(axisExtents(d), tc.zoom)
+			ax := generateAxes(d, tc.zoom, tc.c)
+
+			// Each tick has a line and a label, and each axis has those as well
+			if n := len(ax.P); n > 3*3+3*4*(maxAxisTicks+1) {
+				t.Errorf("axes have %d points", n)
+			}
+			var xTicks []float64
+			for _, p := range ax.P {
+				if p.LabelAlign == "center" && p.Y < 0 {
+					xTicks = append(xTicks, p.X)
+				}
+			}
+			if len(xTicks) == 0 || len(xTicks) > maxAxisTicks+1 {
+				t.Fatalf("%d ticks on the X axis", len(xTicks))
+			}
+
+			// The ticks have to cover the centre, or the nearest end of the axis to it
+			c := math.Max(d.MinX, math.Min(d.MaxX, tc.c.X))
+			if xTicks[0] > c+spacing || xTicks[len(xTicks)-1] < c-spacing {
+				t.Errorf("ticks from %v to %v don't cover %v", xTicks[0], xTicks[len(xTicks)-1], c)
+			}
+
+			// The grid is cut down the same way, to at most five minor lines per major one
+			for _, o := range generateGrid(GRIDXY|GRIDXZ|GRIDYZ, d, tc.zoom, tc.c) {
+				if n := len(o.E); n > 6*5*(maxAxisTicks+1) {
+					t.Errorf("grid has %d lines", n)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+// The opcodes in a command buffer.  Each is followed by its arguments: numbers as they are, and strings as their index
+// in the buffer's string table.  These must match the opcodes understood by drawCommands(), in drawcommands.js
+const (
+	CMDFILLSTYLE float64 = iota
+	CMDSTROKESTYLE
+	CMDLINEWIDTH
+	CMDLINEDASH // Followed by the number of dash lengths, then the lengths
+	CMDFONT
+	CMDTEXTALIGN
+	CMDBEGINPATH
+	CMDMOVETO
+	CMDLINETO
+	CMDELLIPSE
+	CMDCLOSEPATH
+	CMDFILL
+	CMDSTROKE
+	CMDFILLRECT
+	CMDSTROKERECT
+	CMDFILLTEXT // Followed by the text, then the co-ordinates
+)
+
+// A renderer which records the drawing operations into a compact buffer of opcodes and numbers, instead of carrying
+// them out.  Handing the whole buffer to javascript in one go is much quicker than calling into javascript for every
+// operation, as each call from Go has quite a bit of overhead
+type commandBuffer struct {
+	cmds   []float64
+	strs   []string
+	strIdx map[string]int
+}
+
+// Returns a new, empty command buffer
+func newCommandBuffer() *commandBuffer {
+	return &commandBuffer{strIdx: make(map[string]int)}
+}
+
+// Begins a new path
+func (b *commandBuffer) BeginPath() {
+	b.cmds = append(b.cmds, CMDBEGINPATH)
+}
+
+// Closes the current sub-path, back to its starting point
+func (b *commandBuffer) ClosePath() {
+	b.cmds = append(b.cmds, CMDCLOSEPATH)
+}
+
+// Returns the recorded commands
+func (b *commandBuffer) Commands() []float64 {
+	return b.cmds
+}
+
+// Adds a whole ellipse to the current path
+func (b *commandBuffer) Ellipse(x float64, y float64, radiusX float64, radiusY float64) {
+	b.cmds = append(b.cmds, CMDELLIPSE, x, y, radiusX, radiusY)
+}
+
+// Fills the current path with the fill style
+func (b *commandBuffer) Fill() {
+	b.cmds = append(b.cmds, CMDFILL)
+}
+
+// Fills a rectangle with the fill style
+func (b *commandBuffer) FillRect(x float64, y float64, w float64, h float64) {
+	b.cmds = append(b.cmds, CMDFILLRECT, x, y, w, h)
+}
+
+// Draws text with the fill style, font, and text alignment
+func (b *commandBuffer) FillText(text string, x float64, y float64) {
+	b.cmds = append(b.cmds, CMDFILLTEXT, b.str(text), x, y)
+}
+
+// Adds a straight line to the current path
+func (b *commandBuffer) LineTo(x float64, y float64) {
+	b.cmds = append(b.cmds, CMDLINETO, x, y)
+}
+
+// Starts a new sub-path at the given point
+func (b *commandBuffer) MoveTo(x float64, y float64) {
+	b.cmds = append(b.cmds, CMDMOVETO, x, y)
+}
+
+// Empties the buffer, ready to record the next frame.  The memory is kept for reuse
+func (b *commandBuffer) Reset() {
+	b.cmds = b.cmds[:0]
+	b.strs = b.strs[:0]
+	for k := range b.strIdx {
+		delete(b.strIdx, k)
+	}
+}
+
+// Sets the colour used for fills and text
+func (b *commandBuffer) SetFillStyle(colour string) {
+	b.cmds = append(b.cmds, CMDFILLSTYLE, b.str(colour))
+}
+
+// Sets the font used for text
+func (b *commandBuffer) SetFont(font string) {
+	b.cmds = append(b.cmds, CMDFONT, b.str(font))
+}
+
+// Sets the dash pattern for lines.  An empty pattern gives solid lines
+func (b *commandBuffer) SetLineDash(dash []float64) {
+	b.cmds = append(b.cmds, CMDLINEDASH, float64(len(dash)))
+	b.cmds = append(b.cmds, dash...)
+}
+
+// Sets the width of lines
+func (b *commandBuffer) SetLineWidth(w float64) {
+	b.cmds = append(b.cmds, CMDLINEWIDTH, w)
+}
+
+// Sets the colour used for lines
+func (b *commandBuffer) SetStrokeStyle(colour string) {
+	b.cmds = append(b.cmds, CMDSTROKESTYLE, b.str(colour))
+}
+
+// Sets the alignment of text, relative to the point it's drawn at
+func (b *commandBuffer) SetTextAlign(align string) {
+	b.cmds = append(b.cmds, CMDTEXTALIGN, b.str(align))
+}
+
+// Returns the string table, as one string with the entries separated by NUL characters.  Passing one string to
+// javascript is much quicker than passing an array of them
+func (b *commandBuffer) Strings() string {
+	return strings.Join(b.strs, "\x00")
+}
+
+// Draws the current path with the stroke style
+func (b *commandBuffer) Stroke() {
+	b.cmds = append(b.cmds, CMDSTROKE)
+}
+
+// Draws the outline of a rectangle with the stroke style
+func (b *commandBuffer) StrokeRect(x float64, y float64, w float64, h float64) {
+	b.cmds = append(b.cmds, CMDSTROKERECT, x, y, w, h)
+}
+
+// Returns the index of a string in the string table, adding it if it's not already there
+func (b *commandBuffer) str(s string) float64 {
+	i, ok := b.strIdx[s]
+	if !ok {
+		i = len(b.strs)
+		b.strs = append(b.strs, strings.Replace(s, "\x00", "", -1))
+		b.strIdx[s] = i
+	}
+	return float64(i)
+}
+
+// Replays the recorded commands onto another renderer.  This is the Go version of drawCommands(), so the buffer
+// format can be checked without a browser
+func (b *commandBuffer) replay(r Renderer) {
+	c := b.cmds
+	num := func(i int) float64 {
+		if i >= len(c) || math.IsNaN(c[i]) {
+			return 0
+		}
+		return c[i]
+	}
+	str := func(i int) string {
+		n := int(num(i))
+		if n < 0 || n >= len(b.strs) {
+			return ""
+		}
+		return b.strs[n]
+	}
+	for i := 0; i < len(c); {
+		op := c[i]
+		i++
+		switch op {
+		case CMDFILLSTYLE:
+			r.SetFillStyle(str(i))
+			i++
+		case CMDSTROKESTYLE:
+			r.SetStrokeStyle(str(i))
+			i++
+		case CMDLINEWIDTH:
+			r.SetLineWidth(num(i))
+			i++
+		case CMDLINEDASH:
+			n := int(num(i))
+			i++
+			if n < 0 || i+n > len(c) {
+				return
+			}
+			r.SetLineDash(c[i : i+n])
+			i += n
+		case CMDFONT:
+			r.SetFont(str(i))
+			i++
+		case CMDTEXTALIGN:
+			r.SetTextAlign(str(i))
+			i++
+		case CMDBEGINPATH:
+			r.BeginPath()
+		case CMDMOVETO:
+			r.MoveTo(num(i), num(i+1))
+			i += 2
+		case CMDLINETO:
+			r.LineTo(num(i), num(i+1))
+			i += 2
+		case CMDELLIPSE:
+			r.Ellipse(num(i), num(i+1), num(i+2), num(i+3))
+			i += 4
+		case CMDCLOSEPATH:
+			r.ClosePath()
+		case CMDFILL:
+			r.Fill()
+		case CMDSTROKE:
+			r.Stroke()
+		case CMDFILLRECT:
+			r.FillRect(num(i), num(i+1), num(i+2), num(i+3))
+			i += 4
+		case CMDSTROKERECT:
+			r.StrokeRect(num(i), num(i+1), num(i+2), num(i+3))
+			i += 4
+		case CMDFILLTEXT:
+			r.FillText(str(i), num(i+1), num(i+2))
+			i += 3
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// A renderer which records the calls made to it, so the calls made by different ways of drawing can be compared
+type recordingRenderer struct {
+	calls []string
+}
+
+func (r *recordingRenderer) add(name string, args ...interface{}) {
+	r.calls = append(r.calls, fmt.Sprint(name, args))
+}
+
+func (r *recordingRenderer) BeginPath() {
+	r.add("BeginPath")
+}
+
+func (r *recordingRenderer) ClosePath() {
+	r.add("ClosePath")
+}
+
+func (r *recordingRenderer) Ellipse(x float64, y float64, radiusX float64, radiusY float64) {
+	r.add("Ellipse", x, y, radiusX, radiusY)
+}
+
+func (r *recordingRenderer) Fill() {
+	r.add("Fill")
+}
+
+func (r *recordingRenderer) FillRect(x float64, y float64, w float64, h float64) {
+	r.add("FillRect", x, y, w, h)
+}
+
+func (r *recordingRenderer) FillText(text string, x float64, y float64) {
+	r.add("FillText", text, x, y)
+}
+
+func (r *recordingRenderer) LineTo(x float64, y float64) {
+	r.add("LineTo", x, y)
+}
+
+func (r *recordingRenderer) MoveTo(x float64, y float64) {
+	r.add("MoveTo", x, y)
+}
+
+func (r *recordingRenderer) SetFillStyle(colour string) {
+	r.add("SetFillStyle", colour)
+}
+
+func (r *recordingRenderer) SetFont(font string) {
+	r.add("SetFont", font)
+}
+
+func (r *recordingRenderer) SetLineDash(dash []float64) {
+	r.add("SetLineDash", fmt.Sprint(dash))
+}
+
+func (r *recordingRenderer) SetLineWidth(w float64) {
+	r.add("SetLineWidth", w)
+}
+
+func (r *recordingRenderer) SetStrokeStyle(colour string) {
+	r.add("SetStrokeStyle", colour)
+}
+
+func (r *recordingRenderer) SetTextAlign(align string) {
+	r.add("SetTextAlign", align)
+}
+
+func (r *recordingRenderer) Stroke() {
+	r.add("Stroke")
+}
+
+func (r *recordingRenderer) StrokeRect(x float64, y float64, w float64, h float64) {
+	r.add("StrokeRect", x, y, w, h)
+}
+
+func TestCommandBufferReplay(t *testing.T) {
+	for _, v := range []PresetView{TOPVIEW, FRONTVIEW, SIDEVIEW, ISOVIEW} {
+		sc := testScene(v)
+		want := recordingRenderer{}
+		drawScene(&want, sc, 900, 600, INFOSCREEN)
+
+		b := newCommandBuffer()
+		drawScene(b, sc, 900, 600, INFOSCREEN)
+		got := recordingRenderer{}
+		b.replay(&got)
+		if !reflect.DeepEqual(got.calls, want.calls) {
+			t.Errorf("view %v: replayed %d calls, which differ from the %d drawn directly", v, len(got.calls),
+				len(want.calls))
+		}
+
+		// A reset buffer records the same commands again, without any left over from before
+		cmds := append([]float64(nil), b.Commands()...)
+		strs := b.Strings()
+		b.Reset()
+		drawScene(b, sc, 900, 600, INFOSCREEN)
+		if !reflect.DeepEqual(b.Commands(), cmds) || b.Strings() != strs {
+			t.Errorf("view %v: the buffer recorded different commands after being reset", v)
+		}
+	}
+}
+
+func TestCommandBufferStrings(t *testing.T) {
+	b := newCommandBuffer()
+	b.SetFillStyle("red")
+	b.FillText("a\x00b", 1, 2)
+	b.SetStrokeStyle("red")
+	b.SetLineDash([]float64{4, 2})
+	b.SetLineDash(nil)
+
+	// Repeated strings share an entry in the table, and NUL characters are taken out so they can't split an entry
+	if s := b.Strings(); s != "red\x00ab" {
+		t.Errorf("Strings() = %q, want %q", s, "red\x00ab")
+	}
+	want := []float64{CMDFILLSTYLE, 0, CMDFILLTEXT, 1, 1, 2, CMDSTROKESTYLE, 0, CMDLINEDASH, 2, 4, 2, CMDLINEDASH, 0}
+	if !reflect.DeepEqual(b.Commands(), want) {
+		t.Errorf("Commands() = %v, want %v", b.Commands(), want)
+	}
+}
+
+// Times recording a frame into a command buffer.  This is only the Go side of batching, as the tests can't call into
+// javascript.  The difference batching makes to the frame time is measured in the browser, with benchmarkRenderers
+func BenchmarkCommandBuffer(b *testing.B) {
+	sc := testScene(ISOVIEW)
+	buf := newCommandBuffer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		drawScene(buf, sc, 900, 600, INFOSCREEN)
+	}
+}
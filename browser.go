@@ -0,0 +1,923 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall/js"
+	"time"
+)
+
+const (
+	// Scrolling the mouse wheel this many pixels zooms in or out by a factor of two.  A notch of the wheel is usually
+	// about 100 pixels
+	wheelDoublePixels = 300
+
+	// The number of pixels a line is counted as, for mouse wheels which scroll by the line rather than the pixel
+	wheelLinePixels = 40
+)
+
+var (
+	cCall, kCall, mCall js.Callback
+	rCall, uCall, wCall js.Callback
+	doc                 js.Value
+	btnEl, canvasEl     js.Value
+
+	// The text of the performance overlay, as last drawn
+	perfText string
+
+	// The number of device pixels per CSS pixel the canvases are currently sized for
+	pixelRatio float64
+
+	// The WebGL canvas and renderer, when the geometry is being drawn with WebGL
+	glCanvasEl js.Value
+	glRenderer *webglRenderer
+)
+
+func main() {
+	// Initialise canvas
+	doc = js.Global().Get("document")
+	canvasEl = doc.Call("getElementById", "mycanvas")
+	width = doc.Get("body").Get("clientWidth").Float()
+	height = doc.Get("body").Get("clientHeight").Float()
+	graphWidth, graphHeight = width*0.75, height-1
+	pixelRatio = devicePixelRatio()
+	sizeCanvas2D(canvasEl, width, height, pixelRatio)
+	canvasEl.Set("tabIndex", 0) // Not sure if this is needed
+	canvas = &canvasRenderer{ctx: canvasEl.Call("getContext", "2d")}
+
+	// Set up handler for clicks on the "Graph it" button
+	btnEl = doc.Call("getElementById", "update")
+	btnCall := js.NewCallback(buttonHandler)
+	btnEl.Call("addEventListener", "click", btnCall)
+	defer btnCall.Release()
+
+	// Set up handler for the view buttons
+	viewCall := js.NewCallback(viewHandler)
+	for _, id := range []string{"undo", "redo", "resetview", "saveview", "recallview", "topview", "frontview",
+		"sideview", "isoview"} {
+		doc.Call("getElementById", id).Call("addEventListener", "click", viewCall)
+	}
+	defer viewCall.Release()
+
+	// Set up handler for the macro buttons
+	macroCall := js.NewCallback(macroHandler)
+	for _, id := range []string{"record", "stoprecord", "play"} {
+		doc.Call("getElementById", id).Call("addEventListener", "click", macroCall)
+	}
+	defer macroCall.Release()
+
+	// Set up handler for the export buttons
+	exportCall := js.NewCallback(exportHandler)
+	for _, id := range []string{"svg", "png"} {
+		doc.Call("getElementById", id).Call("addEventListener", "click", exportCall)
+	}
+	defer exportCall.Release()
+
+	// Set up handler for the animation export buttons
+	animCall := js.NewCallback(animationHandler)
+	for _, id := range []string{"macrogif", "macrozip", "sweepgif", "sweepzip"} {
+		doc.Call("getElementById", id).Call("addEventListener", "click", animCall)
+	}
+	defer animCall.Release()
+
+	// Set up handler for changes to the grid plane checkboxes
+	gridCall := js.NewCallback(gridHandler)
+	for _, id := range []string{"gridxy", "gridxz", "gridyz"} {
+		doc.Call("getElementById", id).Call("addEventListener", "change", gridCall)
+	}
+	defer gridCall.Release()
+
+	// Set up the mouse click handler
+	cCall = js.NewCallback(clickHandler)
+	canvasEl.Call("addEventListener", "mousedown", cCall)
+	defer cCall.Release()
+
+	// Set up the keypress handler
+	kCall = js.NewCallback(keypressHandler)
+	canvasEl.Call("addEventListener", "keydown", kCall)
+	defer kCall.Release()
+
+	// Stop the browser acting on bound keys too (eg scrolling with the arrow keys).  Go callbacks run too late to do
+	// that, so it's done by a javascript handler (keys.js), which is given the bound keys by publishBoundKeys
+	canvasEl.Call("addEventListener", "keydown", js.Global().Get("preventBoundKeys"))
+
+	// Set up the mouse move handler
+	mCall = js.NewCallback(moveHandler)
+	canvasEl.Call("addEventListener", "mousemove", mCall)
+	defer mCall.Release()
+
+	// Set up the mouse button release handler.  This is on the window rather than the canvas, so drags finish even
+	// when the button is released outside of the canvas
+	uCall = js.NewCallback(releaseHandler)
+	js.Global().Call("addEventListener", "mouseup", uCall)
+	defer uCall.Release()
+
+	// Stop the context menu popping up over the canvas, as the right mouse button is used for panning
+	menuCall := js.NewEventCallback(js.PreventDefault, func(event js.Value) {})
+	canvasEl.Call("addEventListener", "contextmenu", menuCall)
+	defer menuCall.Release()
+
+	// Set the frame renderer going
+	rCall = js.NewCallback(renderFrame)
+	js.Global().Call("requestAnimationFrame", rCall)
+	defer rCall.Release()
+
+	// Set up the pointer event handler, for touch screen gestures
+	pCall := js.NewCallback(pointerHandler)
+	for _, t := range []string{"pointerdown", "pointermove", "pointerup", "pointercancel"} {
+		canvasEl.Call("addEventListener", t, pCall)
+	}
+	defer pCall.Release()
+
+	// Set up the mouse wheel handler
+	wCall = js.NewCallback(wheelHandler)
+	canvasEl.Call("addEventListener", "wheel", wCall)
+	defer wCall.Release()
+
+	// Load any key bindings given in the page URL, either directly as JSON (?keys=...), or as the URL of a JSON file
+	// (?keymap=...)
+	params, err := url.ParseQuery(strings.TrimPrefix(js.Global().Get("location").Get("search").String(), "?"))
+	if err != nil {
+		fmt.Printf("Error: bad page URL parameters: %v\n", err)
+	}
+	if k := params.Get("keys"); k != "" {
+		if err = loadInput([]byte(k)); err != nil {
+			fmt.Printf("Error: bad key bindings: %v\n", err)
+		}
+	}
+	if k := params.Get("keymap"); k != "" {
+		fetchKeymap(k)
+	}
+	publishBoundKeys()
+
+	// Choose how to draw (?renderer=...).  Normally the canvas drawing is batched up, and drawn with one call into
+	// javascript per frame.  "canvas" makes a call per drawing operation instead, and "webgl" draws the geometry with
+	// WebGL
+	switch params.Get("renderer") {
+	case "canvas":
+	case "webgl":
+		useWebGL()
+		canvas = newBatchRenderer(canvasEl.Call("getContext", "2d"))
+	default:
+		canvas = newBatchRenderer(canvasEl.Call("getContext", "2d"))
+	}
+
+	// Let the drawing be timed from the browser console, with benchmarkRenderers(frames)
+	benchCall := js.NewCallback(benchmarkHandler)
+	js.Global().Set("benchmarkRenderers", benchCall)
+	defer benchCall.Release()
+
+	// Let the performance statistics be read from the browser console or scripts, with getPerfStats(callback)
+	perfCall := js.NewCallback(perfHandler)
+	js.Global().Set("getPerfStats", perfCall)
+	defer perfCall.Release()
+
+	// Create the graph objects for the equation and its derivative
+	generateGraphAndDerives(eqStr)
+
+	// Keep the application running
+	done := make(chan struct{}, 0)
+	<-done
+}
+
+// Handler for the animation export buttons.  These capture either the macro script, or a sweep of a parameter in the
+// equation, as an animated GIF or a zip of PNG frames
+func animationHandler(args []js.Value) {
+	id := args[0].Get("target").Get("id").String()
+	w, werr := strconv.Atoi(doc.Call("getElementById", "animwidth").Get("value").String())
+	h, herr := strconv.Atoi(doc.Call("getElementById", "animheight").Get("value").String())
+	fps, ferr := inputNumber("animfps")
+	if werr != nil || herr != nil || ferr != nil || w <= 0 || h <= 0 || w > exportMaxSize || h > exportMaxSize {
+		fmt.Printf("Invalid animation size or frame rate\n")
+		return
+	}
+
+	// Work out what's being captured
+	var capture func(frame func(sc *scene) error) error
+	switch id {
+	case "macrogif", "macrozip":
+		m, err := parseMacro(doc.Call("getElementById", "macro").Get("value").String())
+		if err != nil {
+			fmt.Printf("Error: bad macro script: %v\n", err)
+			return
+		}
+		capture = func(frame func(sc *scene) error) error {
+			return captureMacro(m, fps, frame)
+		}
+	case "sweepgif", "sweepzip":
+		s := sweep{
+			Equation: doc.Call("getElementById", "equation").Get("value").String(),
+			Param:    doc.Call("getElementById", "sweepparam").Get("value").String(),
+		}
+		from, err1 := inputNumber("sweepfrom")
+		to, err2 := inputNumber("sweepto")
+		frames, err3 := strconv.Atoi(doc.Call("getElementById", "sweepframes").Get("value").String())
+		if err1 != nil || err2 != nil || err3 != nil {
+			fmt.Printf("Invalid parameter sweep range\n")
+			return
+		}
+		s.From, s.To, s.Frames = from, to, frames
+		capture = func(frame func(sc *scene) error) error {
+			return captureSweep(s, frame)
+		}
+	default:
+		return
+	}
+
+	// Capture the frames in the background, as graphing and drawing them can take a while
+	go func() {
+		var b bytes.Buffer
+		var err error
+		if strings.HasSuffix(id, "gif") {
+			err = encodeGIF(&b, w, h, fps, capture)
+		} else {
+			err = encodeZip(&b, w, h, capture)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if strings.HasSuffix(id, "gif") {
+			downloadFile("graph.gif", "image/gif", b.Bytes())
+		} else {
+			downloadFile("frames.zip", "application/zip", b.Bytes())
+		}
+	}()
+}
+
+// Times drawing the current scene onto the canvas, both with a javascript call per drawing operation and batched up
+// into one call per frame, then prints the average frame times.  The results are also left in the benchmarkResult
+// javascript variable
+func benchmarkHandler(args []js.Value) {
+	frames := 100
+	if len(args) > 0 && args[0].Type() == js.TypeNumber {
+		frames = args[0].Int()
+	}
+	if frames < 1 {
+		return
+	}
+	perf := js.Global().Get("performance")
+	ctx := canvasEl.Call("getContext", "2d")
+	direct := &canvasRenderer{ctx: ctx}
+	batched := newBatchRenderer(ctx)
+	sc := currentScene()
+
+	// Alternate between the two, so anything else going on in the browser affects them both the same
+	var directTime, batchedTime float64
+	var commands int
+	for i := 0; i < frames; i++ {
+		t := perf.Call("now").Float()
+		drawScene(direct, sc, width, height, INFOSCREEN)
+		t2 := perf.Call("now").Float()
+		drawScene(batched, sc, width, height, INFOSCREEN)
+		commands = len(batched.Commands())
+		batched.Flush()
+		directTime += t2 - t
+		batchedTime += perf.Call("now").Float() - t2
+	}
+	directTime /= float64(frames)
+	batchedTime /= float64(frames)
+	fmt.Printf("Average frame time over %d frames: %.2fms with a call per operation, %.2fms batched (%d numbers "+
+		"per frame)\n", frames, directTime, batchedTime, commands)
+	js.Global().Set("benchmarkResult", map[string]interface{}{
+		"frames":   frames,
+		"direct":   directTime,
+		"batched":  batchedTime,
+		"commands": commands,
+	})
+}
+
+// Simple handler for mouse click events on the "Graph it" button
+func buttonHandler(args []js.Value) {
+	if isCapturing() {
+		return
+	}
+	// Retrieve the new equation for graphing
+	equationEl := doc.Call("getElementById", "equation")
+	newEq := equationEl.Get("value").String()
+
+	// Input validation
+	errEl := doc.Call("getElementById", "errmsg")
+	charEl := doc.Call("getElementById", "errchars")
+	badChars := badEquationChars(newEq)
+	if badChars != "" {
+		// Display error message
+		errEl.Set("style", "display: block;")
+		charEl.Set("innerHTML", badChars)
+		return
+	}
+
+	// Clear any existing error message
+	errEl.Set("style", "display: none;")
+	charEl.Set("innerHTML", "")
+
+	// Create new graph and derivative objects
+	sceneLock.Lock()
+	recordStep(Operation{Op: EQUATION, Eq: newEq})
+	sceneLock.Unlock()
+	generateGraphAndDerives(newEq)
+}
+
+// Simple mouse handler watching for people clicking on the source code link
+func clickHandler(args []js.Value) {
+	if isCapturing() {
+		return
+	}
+	event := args[0]
+	clientX := event.Get("clientX").Float()
+	clientY := event.Get("clientY").Float()
+
+	// If the user clicks the source code URL area, open the URL
+	if clientX > graphWidth && clientY > (height-40) {
+		w := js.Global().Call("open", sourceURL)
+		if w == js.Null() {
+			// Couldn't open a new window, so try loading directly in the existing one instead
+			doc.Set("location", sourceURL)
+		}
+		return
+	}
+
+	pauseTurntable()
+
+	// Mouse presses in the graph area either set the pivot point (alt + left button), or start a zoom box (ctrl + left
+	// button), a pan (right button, or shift + left button), or an arcball rotation (left button)
+	offsetX := event.Get("offsetX").Float()
+	offsetY := event.Get("offsetY").Float()
+	if offsetX >= graphWidth {
+		return
+	}
+	button := event.Get("button").Int()
+	if button == 0 && event.Get("altKey").Bool() {
+		setPivot(offsetX, offsetY)
+		return
+	}
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	switch {
+	case button == 0 && event.Get("ctrlKey").Bool():
+		zoomBoxActive = true
+		zoomBoxX = [2]float64{offsetX, offsetX}
+		zoomBoxY = [2]float64{offsetY, offsetY}
+		spinSpeed = 0
+		markDirty()
+	case button == 2, button == 0 && event.Get("shiftKey").Bool():
+		panActive = true
+		panLastX, panLastY = offsetX, offsetY
+		spinSpeed = 0
+		dragStartView = viewMatrix
+	case button == 0:
+		arcballBegin(offsetX, offsetY, event.Get("timeStamp").Float())
+		dragStartView = viewMatrix
+	}
+}
+
+// Returns the number of device pixels per CSS pixel of the screen the page is on.  This is 2 or more on high density
+// (eg retina) screens, and can change when the window moves between screens or the page is zoomed
+func devicePixelRatio() float64 {
+	r := js.Global().Get("devicePixelRatio")
+	if r.Type() != js.TypeNumber || r.Float() <= 0 {
+		return 1
+	}
+	return r.Float()
+}
+
+// Has the browser download a Blob as a file
+func downloadBlob(name string, blob js.Value) {
+	// Click on a temporary link to the data, then release it once the download has had time to start
+	u := js.Global().Get("URL")
+	link := doc.Call("createElement", "a")
+	link.Set("href", u.Call("createObjectURL", blob))
+	link.Set("download", name)
+	doc.Get("body").Call("appendChild", link)
+	link.Call("click")
+	link.Call("remove")
+	js.Global().Call("setTimeout", u.Get("revokeObjectURL").Call("bind", u, link.Get("href")), 1000)
+}
+
+// Has the browser download the given data as a file
+func downloadFile(name string, mimeType string, data []byte) {
+	a := js.TypedArrayOf(data)
+	blob := js.Global().Get("Blob").New([]interface{}{a}, map[string]interface{}{"type": mimeType})
+	a.Release()
+	downloadBlob(name, blob)
+}
+
+// Draws the current view onto an offscreen canvas of the size given on the page, and downloads it as a PNG image.
+// The size of the browser window doesn't matter, as the layout only depends on the size of the image
+func downloadPNG() {
+	w, werr := strconv.Atoi(doc.Call("getElementById", "pngwidth").Get("value").String())
+	h, herr := strconv.Atoi(doc.Call("getElementById", "pngheight").Get("value").String())
+	if werr != nil || herr != nil || w <= 0 || h <= 0 || w > exportMaxSize || h > exportMaxSize {
+		fmt.Printf("Invalid PNG size: %dx%d\n", w, h)
+		return
+	}
+	c := doc.Call("createElement", "canvas")
+	c.Set("width", w)
+	c.Set("height", h)
+	drawExport(&canvasRenderer{ctx: c.Call("getContext", "2d")}, currentScene(), float64(w), float64(h))
+
+	// The PNG is encoded asynchronously, so the callback releases itself once it has run
+	var pngCall js.Callback
+	pngCall = js.NewCallback(func(args []js.Value) {
+		pngCall.Release()
+		if args[0] == js.Null() {
+			fmt.Println("Couldn't create the PNG image")
+			return
+		}
+		downloadBlob("graph.png", args[0])
+	})
+	c.Call("toBlob", pngCall, "image/png")
+}
+
+// Simple handler for the export buttons, which download the current view as a file
+func exportHandler(args []js.Value) {
+	id := args[0].Get("target").Get("id").String()
+	switch id {
+	case "svg":
+		downloadFile("graph.svg", "image/svg+xml", renderSVG(currentScene(), width, height))
+	case "png":
+		downloadPNG()
+	}
+}
+
+// Fetches a JSON input map from a URL with the browser's fetch(), then loads it.  This returns straight away, and the
+// input map is loaded once it arrives
+func fetchKeymap(u string) {
+	var respCall, textCall, errCall js.Callback
+	done := func() {
+		respCall.Release()
+		textCall.Release()
+		errCall.Release()
+	}
+	respCall = js.NewCallback(func(args []js.Value) {
+		resp := args[0]
+		if !resp.Get("ok").Bool() {
+			fmt.Printf("Error: couldn't load the key bindings: fetching %s: %d %s\n", u, resp.Get("status").Int(),
+				resp.Get("statusText").String())
+			done()
+			return
+		}
+		resp.Call("text").Call("then", textCall, errCall)
+	})
+	textCall = js.NewCallback(func(args []js.Value) {
+		if err := loadInput([]byte(args[0].String())); err != nil {
+			fmt.Printf("Error: couldn't load the key bindings: %v\n", err)
+		} else {
+			publishBoundKeys()
+		}
+		done()
+	})
+	errCall = js.NewCallback(func(args []js.Value) {
+		fmt.Printf("Error: couldn't load the key bindings: %s\n", args[0].Call("toString").String())
+		done()
+	})
+	js.Global().Call("fetch", u).Call("then", respCall, errCall)
+}
+
+// Graphs an equation as if it had been typed in and the "Graph it" button clicked.  Equations with characters which
+// aren't allowed are skipped
+func graphEquation(eq string) {
+	if err := validEquation(eq); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	doc.Call("getElementById", "equation").Set("value", eq)
+	generateGraphAndDerives(eq)
+}
+
+// Handler for changes to the grid plane checkboxes
+func gridHandler(args []js.Value) {
+	if isCapturing() {
+		return
+	}
+	var planes GridPlane
+	if doc.Call("getElementById", "gridxy").Get("checked").Bool() {
+		planes |= GRIDXY
+	}
+	if doc.Call("getElementById", "gridxz").Get("checked").Bool() {
+		planes |= GRIDXZ
+	}
+	if doc.Call("getElementById", "gridyz").Get("checked").Bool() {
+		planes |= GRIDYZ
+	}
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	gridPlanes = planes
+	updateGrid()
+	publishScene()
+}
+
+// Returns the number typed into an input element
+func inputNumber(id string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSpace(doc.Call("getElementById", id).Get("value").String()), 64)
+}
+
+// Simple keyboard handler, which carries out the action bound to the key in the input map
+// Key value info can be found here: https://developer.mozilla.org/en-US/docs/Web/API/KeyboardEvent/key/Key_Values
+func keypressHandler(args []js.Value) {
+	if isCapturing() {
+		return
+	}
+	event := args[0]
+	ctrl := event.Get("ctrlKey").Bool() || event.Get("metaKey").Bool()
+	combo := keyCombo(event.Get("key").String(), ctrl, event.Get("altKey").Bool(), event.Get("shiftKey").Bool())
+	performKey(combo)
+}
+
+// Simple handler for the macro record, stop, and play buttons
+func macroHandler(args []js.Value) {
+	if isCapturing() {
+		return
+	}
+	id := args[0].Get("target").Get("id").String()
+	macroEl := doc.Call("getElementById", "macro")
+	switch id {
+	case "record":
+		startRecording()
+	case "stoprecord":
+		if script := stopRecording(); script != "" {
+			macroEl.Set("value", script)
+		}
+	case "play":
+		m, err := parseMacro(macroEl.Get("value").String())
+		if err != nil {
+			fmt.Printf("Error: bad macro script: %v\n", err)
+			return
+		}
+		playMacro(m)
+	}
+}
+
+// Simple mouse handler watching for people moving the mouse over the source code link
+func moveHandler(args []js.Value) {
+	if isCapturing() {
+		return
+	}
+	event := args[0]
+	clientX := event.Get("clientX").Float()
+	clientY := event.Get("clientY").Float()
+
+	// If the mouse is over the source code link, let the frame renderer know to draw the url in bold
+	over := clientX > graphWidth && clientY > (height-40)
+	if over != highLightSource {
+		highLightSource = over
+		markDirty()
+	}
+
+	// Stretch any zoom box in progress out to the mouse position, and work out how far any pan or arcball drag in
+	// progress has moved
+	offsetX := event.Get("offsetX").Float()
+	offsetY := event.Get("offsetY").Float()
+	sceneLock.Lock()
+	if zoomBoxActive {
+		zoomBoxX[1] = math.Min(offsetX, graphWidth)
+		zoomBoxY[1] = offsetY
+		markDirty()
+	}
+	pan := panActive
+	dx, dy := offsetX-panLastX, offsetY-panLastY
+	panLastX, panLastY = offsetX, offsetY
+	var m matrix
+	if arcballActive {
+		m = arcballDrag(offsetX, offsetY, event.Get("timeStamp").Float())
+	}
+	sceneLock.Unlock()
+
+	// Pan the world space to follow any pan drag in progress
+	if pan {
+		op := panOp(dx, dy, 0)
+		op.noHistory = true // The whole drag is recorded as one change when the mouse button is released
+		submitOperation(op)
+	}
+
+	// Rotate the world space to follow any arcball drag in progress
+	if m != nil {
+		applyTransform(m, true)
+		sceneLock.Lock()
+		opText = "Rotation (mouse drag)."
+		sceneLock.Unlock()
+	}
+}
+
+// Hands the performance statistics to javascript.  Go callbacks can't return a value to javascript, and only run after
+// the call into them has returned, so the statistics are passed to the function given as the argument instead.  They're
+// also left in the perfStats variable
+func perfHandler(args []js.Value) {
+	p := perfSnapshot()
+	stats := map[string]interface{}{
+		"fps":        p.FPS,
+		"frameTime":  p.FrameTime,
+		"renderTime": p.RenderTime,
+		"evalTime":   p.EvalTime,
+		"points":     p.Points,
+		"jsCalls":    p.JSCalls,
+	}
+	js.Global().Set("perfStats", stats)
+	if len(args) > 0 && args[0].Type() == js.TypeFunction {
+		args[0].Invoke(stats)
+	}
+}
+
+// Simple handler for pointer events from touch screens and pens, which are turned into gestures.  One finger rotates
+// the graph like a mouse drag, and two fingers pan, pinch to zoom, and twist to rotate around the Z axis.  Mouse
+// pointers are left to the mouse handlers
+// Reference info can be found here: https://developer.mozilla.org/en-US/docs/Web/API/Pointer_events
+func pointerHandler(args []js.Value) {
+	if isCapturing() {
+		return
+	}
+	event := args[0]
+	if event.Get("pointerType").String() == "mouse" {
+		return
+	}
+	id := event.Get("pointerId").Int()
+	x := event.Get("offsetX").Float()
+	y := event.Get("offsetY").Float()
+	t := event.Get("timeStamp").Float()
+
+	var steps []gestureStep
+	switch event.Get("type").String() {
+	case "pointerdown":
+		if x >= graphWidth {
+			return
+		}
+		canvasEl.Call("setPointerCapture", id)
+		steps = gestures.Down(id, x, y, t)
+	case "pointermove":
+		steps = gestures.Move(id, x, y, t)
+	case "pointerup", "pointercancel":
+		steps = gestures.Up(id, t)
+	}
+
+	for _, s := range steps {
+		switch s.Kind {
+		case ROTATESTART:
+			pauseTurntable()
+			sceneLock.Lock()
+			arcballBegin(s.X, s.Y, s.T)
+			dragStartView = viewMatrix
+			sceneLock.Unlock()
+		case ROTATEDRAG:
+			sceneLock.Lock()
+			m := arcballDrag(s.X, s.Y, s.T)
+			sceneLock.Unlock()
+			applyTransform(m, true)
+			sceneLock.Lock()
+			opText = "Rotation (touch drag)."
+			sceneLock.Unlock()
+		case ROTATEEND:
+			recordDrag(true)
+			sceneLock.Lock()
+			arcballEnd(s.T)
+			sceneLock.Unlock()
+		case TWOSTART:
+			pauseTurntable()
+			sceneLock.Lock()
+			spinSpeed = 0
+			dragStartView = viewMatrix
+			sceneLock.Unlock()
+		case TWOMOVE:
+			// Move the old midpoint between the fingers to the new one, then zoom and twist around it.  The screen's Y
+			// axis points down, so a clockwise twist on screen is a negative rotation around Z
+			cx, cy := screenToWorld(s.X, s.Y)
+			px, py := screenToWorld(s.X-s.DX, s.Y-s.DY)
+			m := aroundPoint(rotateAroundZ(scale(identityMatrix, s.Scale, s.Scale, s.Scale), -s.Angle), Point{X: cx, Y: cy})
+			applyTransform(matrixMult(m, translate(identityMatrix, cx-px, cy-py, 0)), false)
+			sceneLock.Lock()
+			opText = "Pan, pinch, and twist (touch)."
+			sceneLock.Unlock()
+		case TWOEND:
+			recordDrag(true)
+
+			// The graph may have been zoomed or moved, so move the default pivot point to suit
+			sceneLock.Lock()
+			updatePivot()
+			publishScene()
+			sceneLock.Unlock()
+		}
+	}
+}
+
+// Hands the key combinations bound in the input map to javascript, so preventBoundKeys (keys.js) can stop the browser
+// acting on them as well
+func publishBoundKeys() {
+	sceneLock.Lock()
+	keys := make([]interface{}, 0, len(input.Keys))
+	for k := range input.Keys {
+		keys = append(keys, k)
+	}
+	sceneLock.Unlock()
+	js.Global().Set("boundKeys", js.Global().Get("Set").New(keys))
+}
+
+// Mouse handler for button releases, which finishes any arcball, pan, or zoom box drag in progress
+func releaseHandler(args []js.Value) {
+	if isCapturing() {
+		return
+	}
+	sceneLock.Lock()
+	arcball, pan, zoomBox := arcballActive, panActive, zoomBoxActive
+	panActive, zoomBoxActive = false, false
+	sceneLock.Unlock()
+	if arcball || pan {
+		recordDrag(arcball)
+	}
+	if arcball {
+		sceneLock.Lock()
+		arcballEnd(args[0].Get("timeStamp").Float())
+		sceneLock.Unlock()
+	}
+	if zoomBox {
+		markDirty()
+		zoomToBox()
+	}
+}
+
+// Renders one frame of the animation
+func renderFrame(args []js.Value) {
+	// While frames are being captured, they're stepped by the capture's own clock, so just show the latest one
+	if now, ok := tickBrowserClock(args[0].Float()); ok {
+		// Keep the model spinning after an arcball drag is released
+		sceneLock.Lock()
+		m := arcballSpin(now - lastFrameTime)
+		sceneLock.Unlock()
+		if m != nil {
+			applyTransform(m, true)
+		}
+
+		// Turn the turntable, if it's on
+		stepTurntable(now, now-lastFrameTime)
+		lastFrameTime = now
+
+		// Play any macro steps which are due, then step any animation in progress
+		stepMacro(now)
+		stepAnimation(now)
+	}
+	sc := currentScene()
+
+	// Handle window resizing, and the window moving to a screen with a different pixel density
+	curBodyW := doc.Get("body").Get("clientWidth").Float()
+	curBodyH := doc.Get("body").Get("clientHeight").Float()
+	curRatio := devicePixelRatio()
+	if curBodyW != width || curBodyH != height || curRatio != pixelRatio {
+		sceneLock.Lock()
+		width, height, pixelRatio = curBodyW, curBodyH, curRatio
+		graphWidth = width * 0.75
+		graphHeight = height - 1
+		sceneLock.Unlock()
+		sizeCanvas2D(canvasEl, width, height, pixelRatio)
+		if glRenderer != nil {
+			sizeCanvas(glCanvasEl, width, height, pixelRatio)
+			glScene = nil
+		}
+		markDirty()
+	}
+
+	// Draw the scene onto the canvas, but only if something has changed since the last frame
+	start := time.Now()
+	jsCalls = 0
+	drawn := takeDirty()
+	if drawn {
+		drawFrame(sc, width, height)
+		recordFrame(args[0].Float(), true, float64(time.Since(start))/float64(time.Millisecond), scenePoints(sc),
+			jsCalls)
+	} else {
+		recordFrame(args[0].Float(), false, 0, 0, 0)
+	}
+
+	// Show the performance overlay on top, redrawing it whenever its figures change
+	if perfShown() {
+		p := perfSnapshot()
+		text := strings.Join(perfLines(p), "\n")
+		if drawn || text != perfText {
+			if !drawn {
+				drawFrame(sc, width, height)
+			}
+			drawPerf(canvas, p)
+			flushRenderer(canvas)
+			perfText = text
+		}
+	}
+
+	// Schedule the next frame render call
+	js.Global().Call("requestAnimationFrame", rCall)
+}
+
+// Sizes a canvas to the given size in CSS pixels, with a backing store of the given number of device pixels per CSS
+// pixel
+func sizeCanvas(el js.Value, w float64, h float64, ratio float64) {
+	el.Set("width", math.Round(w*ratio))
+	el.Set("height", math.Round(h*ratio))
+	el.Get("style").Set("width", fmt.Sprintf("%vpx", w))
+	el.Get("style").Set("height", fmt.Sprintf("%vpx", h))
+}
+
+// Sizes a canvas for 2D drawing, the same as sizeCanvas.  The drawing is scaled up to match the backing store, so
+// it's still done in CSS pixels, and line widths and text stay the same size on screen, just sharper
+func sizeCanvas2D(el js.Value, w float64, h float64, ratio float64) {
+	sizeCanvas(el, w, h, ratio)
+	el.Call("getContext", "2d").Call("setTransform", ratio, 0, 0, ratio, 0, 0)
+}
+
+// Draws the geometry with WebGL, on a canvas placed underneath the main one.  If WebGL isn't available, or the browser
+// takes the WebGL context away later on, everything carries on being drawn on the main canvas
+func useWebGL() {
+	el := doc.Call("createElement", "canvas")
+	el.Set("id", "glcanvas")
+	sizeCanvas(el, width, height, pixelRatio)
+	r, err := newWebGLRenderer(el)
+	if err != nil {
+		fmt.Printf("Drawing with the canvas instead: %v\n", err)
+		return
+	}
+	var lostCall js.Callback
+	lostCall = js.NewCallback(func(args []js.Value) {
+		fmt.Println("Drawing with the canvas instead: the WebGL context was lost")
+		el.Get("parentNode").Call("removeChild", el)
+		glCanvasEl, glRenderer, glScene = js.Null(), nil, nil
+		markDirty()
+		lostCall.Release()
+	})
+	el.Call("addEventListener", "webglcontextlost", lostCall)
+	canvasEl.Get("parentNode").Call("insertBefore", el, canvasEl)
+	glCanvasEl, glRenderer = el, r
+}
+
+// Handler for clicks on the view buttons
+func viewHandler(args []js.Value) {
+	if isCapturing() {
+		return
+	}
+	id := args[0].Get("target").Get("id").String()
+	pauseTurntable()
+	switch id {
+	case "undo":
+		undo()
+	case "redo":
+		redo()
+	case "resetview":
+		resetView()
+	case "saveview":
+		name := strings.TrimSpace(doc.Call("getElementById", "viewname").Get("value").String())
+		if name == "" {
+			return
+		}
+		saveView(name)
+
+		// Update the list of saved views, selecting the one just saved
+		sel := doc.Call("getElementById", "views")
+		sel.Set("innerHTML", "")
+		for _, n := range savedViewNames() {
+			opt := doc.Call("createElement", "option")
+			opt.Set("value", n)
+			opt.Set("textContent", n)
+			sel.Call("appendChild", opt)
+		}
+		sel.Set("value", name)
+	case "recallview":
+		recallView(doc.Call("getElementById", "views").Get("value").String())
+	case "topview":
+		showPresetView(TOPVIEW)
+	case "frontview":
+		showPresetView(FRONTVIEW)
+	case "sideview":
+		showPresetView(SIDEVIEW)
+	case "isoview":
+		showPresetView(ISOVIEW)
+	}
+}
+
+// Simple mouse handler watching for mouse wheel events.  Browsers give the scroll distance in pixels, lines, or pages
+// depending on the mouse and the system, so it's turned into pixels first.  The zoom factor grows exponentially with
+// the distance, so scrolling the same distance either way zooms in or out by the same amount, and never flips the graph
+// Reference info can be found here: https://developer.mozilla.org/en-US/docs/Web/Events/wheel
+func wheelHandler(args []js.Value) {
+	if isCapturing() {
+		return
+	}
+	event := args[0]
+	wheelDelta := event.Get("deltaY").Float()
+	switch event.Get("deltaMode").Int() {
+	case 1: // DOM_DELTA_LINE
+		wheelDelta *= wheelLinePixels
+	case 2: // DOM_DELTA_PAGE
+		wheelDelta *= graphHeight
+	}
+	if wheelDelta == 0 {
+		return
+	}
+	scaleSize := math.Exp2(wheelDelta / wheelDoublePixels)
+
+	pauseTurntable()
+
+	// Zoom around the point under the mouse, so it stays in place
+	x, y := screenToWorld(event.Get("offsetX").Float(), event.Get("offsetY").Float())
+	submitOperation(zoomOp(scaleSize, x*(1-scaleSize), y*(1-scaleSize)))
+}
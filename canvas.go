@@ -0,0 +1,138 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"math"
+	"syscall/js"
+)
+
+// A renderer which draws onto an HTML5 canvas, through its 2D context
+type canvasRenderer struct {
+	ctx js.Value
+}
+
+// Begins a new path
+func (c *canvasRenderer) BeginPath() {
+	jsCall(c.ctx, "beginPath")
+}
+
+// Closes the current path, back to its starting point
+func (c *canvasRenderer) ClosePath() {
+	jsCall(c.ctx, "closePath")
+}
+
+// Adds a whole ellipse to the current path
+func (c *canvasRenderer) Ellipse(x float64, y float64, radiusX float64, radiusY float64) {
+	jsCall(c.ctx, "ellipse", x, y, radiusX, radiusY, 0, 0, 2*math.Pi)
+}
+
+// Fills the current path with the fill style
+func (c *canvasRenderer) Fill() {
+	jsCall(c.ctx, "fill")
+}
+
+// Fills a rectangle with the fill style
+func (c *canvasRenderer) FillRect(x float64, y float64, w float64, h float64) {
+	jsCall(c.ctx, "fillRect", x, y, w, h)
+}
+
+// Draws text with the fill style, font, and text alignment
+func (c *canvasRenderer) FillText(text string, x float64, y float64) {
+	jsCall(c.ctx, "fillText", text, x, y)
+}
+
+// Adds a straight line to the current path
+func (c *canvasRenderer) LineTo(x float64, y float64) {
+	jsCall(c.ctx, "lineTo", x, y)
+}
+
+// Starts a new sub-path at the given point
+func (c *canvasRenderer) MoveTo(x float64, y float64) {
+	jsCall(c.ctx, "moveTo", x, y)
+}
+
+// Sets the colour used for fills and text
+func (c *canvasRenderer) SetFillStyle(colour string) {
+	jsSet(c.ctx, "fillStyle", colour)
+}
+
+// Sets the font used for text
+func (c *canvasRenderer) SetFont(font string) {
+	jsSet(c.ctx, "font", font)
+}
+
+// Sets the dash pattern for lines.  An empty pattern gives solid lines
+func (c *canvasRenderer) SetLineDash(dash []float64) {
+	d := make([]interface{}, len(dash))
+	for i, v := range dash {
+		d[i] = v
+	}
+	jsCall(c.ctx, "setLineDash", d)
+}
+
+// Sets the width of lines
+func (c *canvasRenderer) SetLineWidth(w float64) {
+	jsSet(c.ctx, "lineWidth", w)
+}
+
+// Sets the colour used for lines
+func (c *canvasRenderer) SetStrokeStyle(colour string) {
+	jsSet(c.ctx, "strokeStyle", colour)
+}
+
+// Sets the alignment of text, relative to the point it's drawn at
+func (c *canvasRenderer) SetTextAlign(align string) {
+	jsSet(c.ctx, "textAlign", align)
+}
+
+// Draws the current path with the stroke style
+func (c *canvasRenderer) Stroke() {
+	jsCall(c.ctx, "stroke")
+}
+
+// Draws the outline of a rectangle with the stroke style
+func (c *canvasRenderer) StrokeRect(x float64, y float64, w float64, h float64) {
+	jsCall(c.ctx, "strokeRect", x, y, w, h)
+}
+
+// A renderer which batches up the drawing for a canvas, then draws it all with a single call into javascript when
+// flushed.  The batch is drawn by drawCommands(), from drawcommands.js
+type batchRenderer struct {
+	*commandBuffer
+	ctx  js.Value
+	draw js.Value
+}
+
+// Returns a new batching renderer for a canvas 2D context
+func newBatchRenderer(ctx js.Value) *batchRenderer {
+	return &batchRenderer{commandBuffer: newCommandBuffer(), ctx: ctx, draw: js.Global().Get("drawCommands")}
+}
+
+// Draws everything batched up since the last flush onto the canvas, then empties the batch.  If drawcommands.js
+// hasn't been loaded, the batch is drawn with individual calls instead
+func (b *batchRenderer) Flush() {
+	if b.draw.Type() != js.TypeFunction {
+		b.replay(&canvasRenderer{ctx: b.ctx})
+		b.Reset()
+		return
+	}
+	a := js.TypedArrayOf(b.Commands())
+	jsCalls++
+	b.draw.Invoke(b.ctx, a, b.Strings())
+	a.Release()
+	b.Reset()
+}
+
+// Calls a javascript method, counting the call for the performance statistics
+func jsCall(v js.Value, method string, args ...interface{}) js.Value {
+	jsCalls++
+	return v.Call(method, args...)
+}
+
+// Sets a javascript property, counting it as a call for the performance statistics
+func jsSet(v js.Value, property string, x interface{}) {
+	jsCalls++
+	v.Set(property, x)
+}
@@ -0,0 +1,71 @@
+// Draws a buffer of canvas commands, built up in Go by the commandBuffer type (batch.go).  cmds is a Float64Array of
+// opcodes, each followed by its arguments, and strs holds the strings they refer to, separated by NUL characters.
+// The opcodes must match the CMD constants in batch.go
+function drawCommands(ctx, cmds, strs) {
+    const s = strs.split("\0");
+    const n = cmds.length;
+    let i = 0;
+    while (i < n) {
+        switch (cmds[i++]) {
+            case 0: // CMDFILLSTYLE
+                ctx.fillStyle = s[cmds[i++]];
+                break;
+            case 1: // CMDSTROKESTYLE
+                ctx.strokeStyle = s[cmds[i++]];
+                break;
+            case 2: // CMDLINEWIDTH
+                ctx.lineWidth = cmds[i++];
+                break;
+            case 3: { // CMDLINEDASH
+                const l = cmds[i++];
+                ctx.setLineDash(Array.from(cmds.subarray(i, i + l)));
+                i += l;
+                break;
+            }
+            case 4: // CMDFONT
+                ctx.font = s[cmds[i++]];
+                break;
+            case 5: // CMDTEXTALIGN
+                ctx.textAlign = s[cmds[i++]];
+                break;
+            case 6: // CMDBEGINPATH
+                ctx.beginPath();
+                break;
+            case 7: // CMDMOVETO
+                ctx.moveTo(cmds[i], cmds[i + 1]);
+                i += 2;
+                break;
+            case 8: // CMDLINETO
+                ctx.lineTo(cmds[i], cmds[i + 1]);
+                i += 2;
+                break;
+            case 9: // CMDELLIPSE
+                ctx.ellipse(cmds[i], cmds[i + 1], cmds[i + 2], cmds[i + 3], 0, 0, 2 * Math.PI);
+                i += 4;
+                break;
+            case 10: // CMDCLOSEPATH
+                ctx.closePath();
+                break;
+            case 11: // CMDFILL
+                ctx.fill();
+                break;
+            case 12: // CMDSTROKE
+                ctx.stroke();
+                break;
+            case 13: // CMDFILLRECT
+                ctx.fillRect(cmds[i], cmds[i + 1], cmds[i + 2], cmds[i + 3]);
+                i += 4;
+                break;
+            case 14: // CMDSTROKERECT
+                ctx.strokeRect(cmds[i], cmds[i + 1], cmds[i + 2], cmds[i + 3]);
+                i += 4;
+                break;
+            case 15: // CMDFILLTEXT
+                ctx.fillText(s[cmds[i]], cmds[i + 1], cmds[i + 2]);
+                i += 3;
+                break;
+            default:
+                return;
+        }
+    }
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	// Exported images are laid out as if they were drawn on a canvas of about this size, then scaled up to the
+	// resolution asked for.  That way line widths and text grow with the image, rather than becoming too thin and small
+	// to see at high resolutions
+	exportBaseWidth  = 1200
+	exportBaseHeight = 800
+
+	// The largest image width or height which can be exported, in pixels
+	exportMaxSize = 16384
+)
+
+// A renderer which scales everything drawn with it (co-ordinates, line widths, dash patterns, and font sizes) by a
+// fixed factor, before passing it on to another renderer
+type scaledRenderer struct {
+	r Renderer
+	k float64
+}
+
+// Begins a new path
+func (s *scaledRenderer) BeginPath() {
+	s.r.BeginPath()
+}
+
+// Closes the current sub-path, back to its starting point
+func (s *scaledRenderer) ClosePath() {
+	s.r.ClosePath()
+}
+
+// Adds a whole ellipse to the current path
+func (s *scaledRenderer) Ellipse(x float64, y float64, radiusX float64, radiusY float64) {
+	s.r.Ellipse(x*s.k, y*s.k, radiusX*s.k, radiusY*s.k)
+}
+
+// Fills the current path with the fill style
+func (s *scaledRenderer) Fill() {
+	s.r.Fill()
+}
+
+// Fills a rectangle with the fill style
+func (s *scaledRenderer) FillRect(x float64, y float64, w float64, h float64) {
+	s.r.FillRect(x*s.k, y*s.k, w*s.k, h*s.k)
+}
+
+// Draws text with the fill style, font, and text alignment
+func (s *scaledRenderer) FillText(text string, x float64, y float64) {
+	s.r.FillText(text, x*s.k, y*s.k)
+}
+
+// Adds a straight line to the current path
+func (s *scaledRenderer) LineTo(x float64, y float64) {
+	s.r.LineTo(x*s.k, y*s.k)
+}
+
+// Starts a new sub-path at the given point
+func (s *scaledRenderer) MoveTo(x float64, y float64) {
+	s.r.MoveTo(x*s.k, y*s.k)
+}
+
+// Sets the colour used for fills and text
+func (s *scaledRenderer) SetFillStyle(colour string) {
+	s.r.SetFillStyle(colour)
+}
+
+// Sets the font used for text
+func (s *scaledRenderer) SetFont(font string) {
+	s.r.SetFont(scaleFont(font, s.k))
+}
+
+// Sets the dash pattern for lines
+func (s *scaledRenderer) SetLineDash(dash []float64) {
+	d := make([]float64, len(dash))
+	for i, v := range dash {
+		d[i] = v * s.k
+	}
+	s.r.SetLineDash(d)
+}
+
+// Sets the width of lines
+func (s *scaledRenderer) SetLineWidth(w float64) {
+	s.r.SetLineWidth(w * s.k)
+}
+
+// Sets the colour used for lines
+func (s *scaledRenderer) SetStrokeStyle(colour string) {
+	s.r.SetStrokeStyle(colour)
+}
+
+// Sets the alignment of text, relative to the point it's drawn at
+func (s *scaledRenderer) SetTextAlign(align string) {
+	s.r.SetTextAlign(align)
+}
+
+// Draws the current path with the stroke style
+func (s *scaledRenderer) Stroke() {
+	s.r.Stroke()
+}
+
+// Draws the outline of a rectangle with the stroke style
+func (s *scaledRenderer) StrokeRect(x float64, y float64, w float64, h float64) {
+	s.r.StrokeRect(x*s.k, y*s.k, w*s.k, h*s.k)
+}
+
+// Draws a scene snapshot with a renderer for an exported image of the given size.  The scene is laid out at the base
+// size, then scaled up to fill the image
+func drawExport(r Renderer, sc *scene, w float64, h float64) {
+	k := exportScale(w, h)
+	drawScene(&scaledRenderer{r: r, k: k}, sc, w/k, h/k, INFOEXPORT)
+}
+
+// Returns the scale factor to draw an exported image of the given size with.  Images smaller than the base size are
+// drawn at their actual size
+func exportScale(w float64, h float64) float64 {
+	k := w / exportBaseWidth
+	if kh := h / exportBaseHeight; kh < k {
+		k = kh
+	}
+	if k < 1 {
+		return 1
+	}
+	return k
+}
+
+// Returns a CSS font string with its pixel size multiplied by the given factor
+func scaleFont(font string, k float64) string {
+	f := strings.Fields(font)
+	for i, p := range f {
+		if strings.HasSuffix(p, "px") {
+			if s, err := strconv.ParseFloat(strings.TrimSuffix(p, "px"), 64); err == nil {
+				f[i] = strconv.FormatFloat(s*k, 'f', -1, 64) + "px"
+			}
+		}
+	}
+	return strings.Join(f, " ")
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// A parameter sweep, which graphs an equation once for each of a range of values of one of its parameters.  For
+// example, the equation "x^2 + a*x" with the parameter "a" swept from -2 to 2
+type sweep struct {
+	Equation string
+	Param    string
+	From, To float64
+	Frames   int
+}
+
+const (
+	// The most frames which can be captured in one go, to stop a mistake from running out of memory
+	maxCaptureFrames = 3000
+
+	// The most pixels, over all of the frames, which can be captured into a GIF.  The GIF encoder needs every frame in
+	// memory at once, at a byte per pixel, so this is about how many bytes they can take up.  At 1200x800 it's enough
+	// for a little over 400 frames
+	maxCapturePixels = 400 * 1000 * 1000
+)
+
+var (
+	// True while frames are being captured.  User input is ignored while it's set, so only the capture changes the
+	// scene.  Protected by sceneLock
+	capturing bool
+
+	paramName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+)
+
+// Plays back a macro against a frame clock which moves on by exactly 1/fps of a second per frame, calling frame with a
+// snapshot of the scene for each one.  The frames don't depend on how long anything takes to draw, so the same macro
+// always gives the same frames.  If frame returns an error, the capture stops with it
+func captureMacro(m macro, fps float64, frame func(sc *scene) error) error {
+	if fps <= 0 || math.IsInf(fps, 0) || math.IsNaN(fps) {
+		return fmt.Errorf("invalid frame rate: %v", fps)
+	}
+	if err := startCapture(); err != nil {
+		return err
+	}
+	defer stopCapture()
+
+	// Swap in a manual frame clock, so nothing else moves the animations on while capturing
+	c := &manualClock{}
+	sceneLock.Lock()
+	saved := clock
+	clock = c
+	sceneLock.Unlock()
+	defer func() {
+		sceneLock.Lock()
+		clock = saved
+		sceneLock.Unlock()
+	}()
+
+	playMacro(m)
+	for i := 0; i < maxCaptureFrames; i++ {
+		now := float64(i) * 1000 / fps
+		sceneLock.Lock()
+		c.t = now
+		sceneLock.Unlock()
+		stepMacro(now)
+		waitForEquation()
+		stepAnimation(now)
+		if err := frame(currentScene()); err != nil {
+			stopMacro()
+			return err
+		}
+
+		sceneLock.Lock()
+		done := player == nil && anim == nil && len(pending) == 0
+		sceneLock.Unlock()
+		if done {
+			return nil
+		}
+	}
+	stopMacro()
+	return fmt.Errorf("macro is longer than %d frames", maxCaptureFrames)
+}
+
+// Graphs each step of a parameter sweep, calling frame with a snapshot of the scene for each one.  The view is kept
+// the same throughout, and the equation graphed beforehand is put back at the end.  If frame returns an error, the
+// capture stops with it
+func captureSweep(s sweep, frame func(sc *scene) error) (err error) {
+	if !paramName.MatchString(s.Param) || s.Param == "x" {
+		return fmt.Errorf("invalid parameter name: %q", s.Param)
+	}
+	if !regexp.MustCompile(`\b` + s.Param + `\b`).MatchString(s.Equation) {
+		return fmt.Errorf("parameter %q isn't in the equation", s.Param)
+	}
+	if err := validEquation(s.Equation, s.Param); err != nil {
+		return err
+	}
+	if s.Frames < 1 || s.Frames > maxCaptureFrames {
+		return fmt.Errorf("invalid number of frames: %d", s.Frames)
+	}
+	if err := startCapture(); err != nil {
+		return err
+	}
+	defer stopCapture()
+
+	// Graphing the sweep's equations clears the history, so it's put back afterwards along with the equation and view
+	sceneLock.Lock()
+	savedEq, view := graphEq, viewMatrix
+	savedUndo, savedRedo := undoStack, redoStack
+	sceneLock.Unlock()
+	defer func() {
+		if savedEq != "" {
+			graphWithView(savedEq, view)
+		}
+		sceneLock.Lock()
+		undoStack, redoStack = savedUndo, savedRedo
+		sceneLock.Unlock()
+	}()
+	for i := 0; i < s.Frames; i++ {
+		v := s.From
+		if s.Frames > 1 {
+			v += (s.To - s.From) * float64(i) / float64(s.Frames-1)
+		}
+		graphWithView(substituteParam(s.Equation, s.Param, v), view)
+		sceneLock.Lock()
+		opText = fmt.Sprintf("%s = %s", s.Param, strconv.FormatFloat(v, 'g', 4, 64))
+		publishScene()
+		sceneLock.Unlock()
+		if err = frame(currentScene()); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Captures frames at the given size, writing them out as an animated GIF which shows them at the given frame rate.  The
+// capture is stopped with an error once the frames would take more than maxCapturePixels
+func encodeGIF(out io.Writer, w int, h int, fps float64, capture func(frame func(sc *scene) error) error) error {
+	if w <= 0 || h <= 0 {
+		return fmt.Errorf("invalid image size: %dx%d", w, h)
+	}
+	var g gif.GIF
+	delay := int(math.Round(100 / fps)) // GIF frame delays are in hundredths of a second
+	if delay < 1 {
+		delay = 1
+	}
+	err := capture(func(sc *scene) error {
+		if (len(g.Image)+1)*w*h > maxCapturePixels {
+			return fmt.Errorf("too many frames for a %dx%d GIF, the most is %d", w, h, maxCapturePixels/(w*h))
+		}
+		g.Image = append(g.Image, palettedImage(renderImage(sc, w, h)))
+		g.Delay = append(g.Delay, delay)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return gif.EncodeAll(out, &g)
+}
+
+// Captures frames at the given size, writing them out as a zip file of numbered PNG images.  The files in the zip
+// have no modification times, so the same frames always give the same zip file.  Each frame is compressed and written
+// out as soon as it's drawn, so only one frame is kept in memory at a time
+func encodeZip(out io.Writer, w int, h int, capture func(frame func(sc *scene) error) error) error {
+	if w <= 0 || h <= 0 {
+		return fmt.Errorf("invalid image size: %dx%d", w, h)
+	}
+	z := zip.NewWriter(out)
+	n := 0
+	err := capture(func(sc *scene) error {
+		f, err := z.CreateHeader(&zip.FileHeader{Name: fmt.Sprintf("frame%04d.png", n), Method: zip.Deflate})
+		if err != nil {
+			return err
+		}
+		n++
+		return png.Encode(f, renderImage(sc, w, h))
+	})
+	if cerr := z.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Graphs an equation, then puts the view back to the given one
+func graphWithView(eq string, view matrix) {
+	generateGraphAndDerives(eq)
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	viewMatrix = view
+	updateAxes()
+	updateGrid()
+	updatePivot()
+	publishScene()
+}
+
+// Returns true while frames are being captured
+func isCapturing() bool {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	return capturing
+}
+
+// Converts an image to a paletted one for GIF output.  The scenes are drawn without anti-aliasing, so they nearly
+// always have few enough colours to use exactly.  Otherwise the colours are matched as closely as possible to a
+// standard palette
+func palettedImage(img *image.RGBA) *image.Paletted {
+	seen := make(map[color.RGBA]bool)
+	for i := 0; i < len(img.Pix) && len(seen) <= 256; i += 4 {
+		seen[color.RGBA{R: img.Pix[i], G: img.Pix[i+1], B: img.Pix[i+2], A: img.Pix[i+3]}] = true
+	}
+	if len(seen) > 256 {
+		p := image.NewPaletted(img.Bounds(), palette.Plan9)
+		draw.Draw(p, p.Rect, img, img.Bounds().Min, draw.Src)
+		return p
+	}
+
+	// Sort the colours, so the palette doesn't depend upon the order of the map
+	cols := make([]color.RGBA, 0, len(seen))
+	for c := range seen {
+		cols = append(cols, c)
+	}
+	sort.Slice(cols, func(i, j int) bool {
+		a, b := cols[i], cols[j]
+		return uint32(a.R)<<24|uint32(a.G)<<16|uint32(a.B)<<8|uint32(a.A) <
+			uint32(b.R)<<24|uint32(b.G)<<16|uint32(b.B)<<8|uint32(b.A)
+	})
+	pal := make(color.Palette, len(cols))
+	index := make(map[color.RGBA]uint8, len(cols))
+	for i, c := range cols {
+		pal[i] = c
+		index[c] = uint8(i)
+	}
+	p := image.NewPaletted(img.Bounds(), pal)
+	for i, j := 0, 0; i < len(img.Pix); i, j = i+4, j+1 {
+		p.Pix[j] = index[color.RGBA{R: img.Pix[i], G: img.Pix[i+1], B: img.Pix[i+2], A: img.Pix[i+3]}]
+	}
+	return p
+}
+
+// Starts capturing frames.  Only one capture can run at a time
+func startCapture() error {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	if capturing {
+		return fmt.Errorf("frames are already being captured")
+	}
+	capturing = true
+	return nil
+}
+
+// Finishes capturing frames
+func stopCapture() {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	capturing = false
+}
+
+// Returns an equation with every use of a parameter replaced by the given value
+func substituteParam(eq string, param string, v float64) string {
+	return regexp.MustCompile(`\b`+regexp.QuoteMeta(param)+`\b`).ReplaceAllLiteralString(eq,
+		"("+strconv.FormatFloat(v, 'f', -1, 64)+")")
+}
+
+// Waits for any equation step of the macro being played back to finish graphing
+func waitForEquation() {
+	for {
+		sceneLock.Lock()
+		waiting := player != nil && player.waiting
+		sceneLock.Unlock()
+		if !waiting {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
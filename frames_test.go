@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"image/gif"
+	"testing"
+)
+
+func TestCaptureMacroDeterministic(t *testing.T) {
+	m, err := parseMacro(`{"view": null, "steps": [
+		{"wait": 100, "op": {"op": "rotate", "t": 500, "easing": "easeinout", "z": 90}},
+		{"wait": 200, "op": {"op": "zoom", "t": 300, "easing": "spring", "s": 1.5}},
+		{"wait": 50, "op": {"op": "translate", "t": 250, "x": 0.5, "y": -0.5}}
+	]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, e := range []Easing{EASEINOUT, SPRING, LINEAR} {
+		if m.Steps[i].Op.E != e {
+			t.Fatalf("step %d has easing %v, want %v", i, m.Steps[i].Op.E, e)
+		}
+	}
+
+	// Capture the macro twice from the same starting scene.  The frames are stepped by the capture's own clock, so
+	// the GIFs have to come out exactly the same
+	capture := func() []byte {
+		sc := testScene(TOPVIEW)
+		sceneLock.Lock()
+		worldSpace = sc.objects
+		graphDomain = objectDomain(sc.objects[len(sc.objects)-1:])
+		viewMatrix = identityMatrix
+		anim, pending, player = nil, nil, nil
+		publishScene()
+		sceneLock.Unlock()
+
+		var b bytes.Buffer
+		err := encodeGIF(&b, 300, 200, 20, func(frame func(sc *scene) error) error {
+			return captureMacro(m, 20, frame)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b.Bytes()
+	}
+	a, b := capture(), capture()
+	if !bytes.Equal(a, b) {
+		t.Fatal("capturing the same macro twice gave different GIFs")
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Image) < 20 {
+		t.Errorf("GIF has only %d frames", len(g.Image))
+	}
+	if isCapturing() {
+		t.Error("still capturing after the capture finished")
+	}
+}
+
+func TestEncodeGIFLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		w, h   int
+		frames int
+		ok     bool
+	}{
+		{"small", 30, 20, 5, true},
+
+		// A frame this big would need over a gigabyte to draw, so it has to be refused before it's drawn
+		{"too big", 20000, 20001, 1, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sc := &scene{view: identityMatrix}
+			var b bytes.Buffer
+			err := encodeGIF(&b, tc.w, tc.h, 20, func(frame func(sc *scene) error) error {
+				for i := 0; i < tc.frames; i++ {
+					if err := frame(sc); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if !tc.ok {
+				if err == nil {
+					t.Errorf("no error for %d frames at %dx%d", tc.frames, tc.w, tc.h)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			g, err := gif.DecodeAll(&b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(g.Image) != tc.frames {
+				t.Errorf("GIF has %d frames, want %d", len(g.Image), tc.frames)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import "math"
+
+// The kinds of gesture step the gesture recogniser reports
+type GestureKind int
+
+const (
+	ROTATESTART GestureKind = iota // One finger touched down, at X, Y
+	ROTATEDRAG                     // The finger moved, to X, Y
+	ROTATEEND                      // The finger lifted, or a second finger touched down
+	TWOSTART                       // A second finger touched down, starting a two finger gesture
+	TWOMOVE                        // The fingers moved.  See gestureStep for the details
+	TWOEND                         // One of the two fingers lifted
+)
+
+// The states of the gesture recogniser
+type gestureState int
+
+const (
+	GESTUREIDLE   gestureState = iota // No fingers down
+	GESTUREONE                        // One finger down, rotating
+	GESTURETWO                        // Two fingers down, panning, pinching, and twisting
+	GESTUREFINISH                     // Waiting for the rest of the fingers to lift, after a two finger gesture
+)
+
+// One step of a recognised gesture.  For two finger moves, the midpoint between the fingers moved by DX, DY, the
+// distance between them changed by the factor Scale, and the line between them turned clockwise (on screen) by Angle
+// degrees.  X, Y is the new midpoint, which the pinch and twist are centred on
+type gestureStep struct {
+	Kind   GestureKind
+	X, Y   float64
+	DX, DY float64
+	Scale  float64
+	Angle  float64
+	T      float64 // Event timestamp, in milliseconds
+}
+
+// A gesture recogniser.  It's a state machine fed with pointer down, move, and up events, which turns them into
+// gesture steps.  There's nothing browser specific in here, so it can be driven by synthetic event sequences too
+type gestureRecogniser struct {
+	state    gestureState
+	pointers map[int]Point // The current position of each pointer that's down, by pointer ID
+	order    []int         // The pointer IDs, in the order they touched down
+}
+
+// Handles a pointer touching down
+func (g *gestureRecogniser) Down(id int, x float64, y float64, t float64) (steps []gestureStep) {
+	if _, ok := g.pointers[id]; ok {
+		return
+	}
+	g.pointers[id] = Point{X: x, Y: y}
+	g.order = append(g.order, id)
+	switch g.state {
+	case GESTUREIDLE:
+		g.state = GESTUREONE
+		steps = append(steps, gestureStep{Kind: ROTATESTART, X: x, Y: y, T: t})
+	case GESTUREONE:
+		g.state = GESTURETWO
+		mx, my := g.midpoint()
+		steps = append(steps, gestureStep{Kind: ROTATEEND, T: t}, gestureStep{Kind: TWOSTART, X: mx, Y: my, T: t})
+	}
+
+	// Any more fingers than two are ignored, until the gesture finishes
+	return
+}
+
+// Handles a pointer moving
+func (g *gestureRecogniser) Move(id int, x float64, y float64, t float64) (steps []gestureStep) {
+	if _, ok := g.pointers[id]; !ok {
+		return
+	}
+	switch g.state {
+	case GESTUREONE:
+		g.pointers[id] = Point{X: x, Y: y}
+		steps = append(steps, gestureStep{Kind: ROTATEDRAG, X: x, Y: y, T: t})
+
+	case GESTURETWO:
+		// Only the first two fingers take part
+		if id != g.order[0] && id != g.order[1] {
+			g.pointers[id] = Point{X: x, Y: y}
+			return
+		}
+		a, b := g.fingers()
+		mx, my := g.midpoint()
+		g.pointers[id] = Point{X: x, Y: y}
+		a2, b2 := g.fingers()
+		mx2, my2 := g.midpoint()
+
+		// Work out the pinch and twist from the line between the fingers.  Fingers on top of each other have no line
+		// between them, so don't pinch or twist anything
+		s := gestureStep{Kind: TWOMOVE, X: mx2, Y: my2, DX: mx2 - mx, DY: my2 - my, Scale: 1, T: t}
+		d := math.Hypot(b.X-a.X, b.Y-a.Y)
+		d2 := math.Hypot(b2.X-a2.X, b2.Y-a2.Y)
+		if d > 0 && d2 > 0 {
+			s.Scale = d2 / d
+			angle := math.Atan2(b2.Y-a2.Y, b2.X-a2.X) - math.Atan2(b.Y-a.Y, b.X-a.X)
+			s.Angle = math.Remainder(angle, 2*math.Pi) * 180 / math.Pi
+		}
+		steps = append(steps, s)
+
+	default:
+		g.pointers[id] = Point{X: x, Y: y}
+	}
+	return
+}
+
+// Handles a pointer lifting, or being cancelled by the browser
+func (g *gestureRecogniser) Up(id int, t float64) (steps []gestureStep) {
+	if _, ok := g.pointers[id]; !ok {
+		return
+	}
+	twoFinger := g.state == GESTURETWO && (id == g.order[0] || id == g.order[1])
+	delete(g.pointers, id)
+	for i, o := range g.order {
+		if o == id {
+			g.order = append(g.order[:i], g.order[i+1:]...)
+			break
+		}
+	}
+
+	switch {
+	case len(g.pointers) == 0:
+		switch g.state {
+		case GESTUREONE:
+			steps = append(steps, gestureStep{Kind: ROTATEEND, T: t})
+		case GESTURETWO:
+			steps = append(steps, gestureStep{Kind: TWOEND, T: t})
+		}
+		g.state = GESTUREIDLE
+	case twoFinger:
+		// Lifting one of the two fingers finishes the gesture.  The rest need to lift before another one starts, so
+		// the remaining finger doesn't suddenly start rotating things
+		steps = append(steps, gestureStep{Kind: TWOEND, T: t})
+		g.state = GESTUREFINISH
+	}
+	return
+}
+
+// Returns the positions of the two fingers of a two finger gesture
+func (g *gestureRecogniser) fingers() (Point, Point) {
+	return g.pointers[g.order[0]], g.pointers[g.order[1]]
+}
+
+// Returns the midpoint between the two fingers of a two finger gesture
+func (g *gestureRecogniser) midpoint() (float64, float64) {
+	a, b := g.fingers()
+	return (a.X + b.X) / 2, (a.Y + b.Y) / 2
+}
+
+// Returns a new gesture recogniser, with no pointers down
+func newGestureRecogniser() *gestureRecogniser {
+	return &gestureRecogniser{pointers: make(map[int]Point)}
+}
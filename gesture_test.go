@@ -0,0 +1,176 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// A synthetic pointer event, for feeding to the gesture recogniser
+type pointerEvent struct {
+	kind string // "down", "move", or "up".  The browser's pointercancel is handled as "up"
+	id   int
+	x, y float64
+	t    float64
+}
+
+// Returns true if two gesture steps are the same, to within rounding error
+func stepNear(a gestureStep, b gestureStep) bool {
+	near := func(x float64, y float64) bool {
+		return math.Abs(x-y) < 1e-9
+	}
+	return a.Kind == b.Kind && near(a.X, b.X) && near(a.Y, b.Y) && near(a.DX, b.DX) && near(a.DY, b.DY) &&
+		near(a.Scale, b.Scale) && near(a.Angle, b.Angle) && a.T == b.T
+}
+
+func TestGestureRecogniser(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []pointerEvent
+		want   []gestureStep
+	}{
+		{
+			name: "tap",
+			events: []pointerEvent{
+				{"down", 1, 10, 20, 0},
+				{"up", 1, 0, 0, 50},
+			},
+			want: []gestureStep{
+				{Kind: ROTATESTART, X: 10, Y: 20, T: 0},
+				{Kind: ROTATEEND, T: 50},
+			},
+		},
+		{
+			name: "one finger drag",
+			events: []pointerEvent{
+				{"down", 1, 10, 20, 0},
+				{"move", 1, 15, 25, 10},
+				{"move", 1, 30, 20, 20},
+				{"up", 1, 0, 0, 30},
+			},
+			want: []gestureStep{
+				{Kind: ROTATESTART, X: 10, Y: 20, T: 0},
+				{Kind: ROTATEDRAG, X: 15, Y: 25, T: 10},
+				{Kind: ROTATEDRAG, X: 30, Y: 20, T: 20},
+				{Kind: ROTATEEND, T: 30},
+			},
+		},
+		{
+			name: "pinch",
+			events: []pointerEvent{
+				{"down", 1, 100, 100, 0},
+				{"down", 2, 200, 100, 5},
+				{"move", 2, 300, 100, 10},
+				{"move", 1, 0, 100, 20},
+				{"up", 2, 0, 0, 30},
+				{"up", 1, 0, 0, 40},
+			},
+			want: []gestureStep{
+				{Kind: ROTATESTART, X: 100, Y: 100, T: 0},
+				{Kind: ROTATEEND, T: 5},
+				{Kind: TWOSTART, X: 150, Y: 100, T: 5},
+				{Kind: TWOMOVE, X: 200, Y: 100, DX: 50, Scale: 2, T: 10},
+				{Kind: TWOMOVE, X: 150, Y: 100, DX: -50, Scale: 1.5, T: 20},
+				{Kind: TWOEND, T: 30},
+			},
+		},
+		{
+			name: "twist",
+			events: []pointerEvent{
+				{"down", 1, 100, 100, 0},
+				{"down", 2, 200, 100, 5},
+				{"move", 2, 100, 200, 10},
+				{"up", 1, 0, 0, 20},
+				{"up", 2, 0, 0, 30},
+			},
+			want: []gestureStep{
+				{Kind: ROTATESTART, X: 100, Y: 100, T: 0},
+				{Kind: ROTATEEND, T: 5},
+				{Kind: TWOSTART, X: 150, Y: 100, T: 5},
+				{Kind: TWOMOVE, X: 100, Y: 150, DX: -50, DY: 50, Scale: 1, Angle: 90, T: 10},
+				{Kind: TWOEND, T: 20},
+			},
+		},
+		{
+			// The browser reports each finger's move separately, so the first one briefly pinches and twists, and the
+			// second one undoes it
+			name: "two finger pan",
+			events: []pointerEvent{
+				{"down", 1, 100, 100, 0},
+				{"down", 2, 200, 100, 5},
+				{"move", 1, 110, 120, 10},
+				{"move", 2, 210, 120, 15},
+				{"up", 1, 0, 0, 20},
+				{"up", 2, 0, 0, 30},
+			},
+			want: []gestureStep{
+				{Kind: ROTATESTART, X: 100, Y: 100, T: 0},
+				{Kind: ROTATEEND, T: 5},
+				{Kind: TWOSTART, X: 150, Y: 100, T: 5},
+				{Kind: TWOMOVE, X: 155, Y: 110, DX: 5, DY: 10, Scale: math.Hypot(90, -20) / 100,
+					Angle: math.Atan2(-20, 90) * 180 / math.Pi, T: 10},
+				{Kind: TWOMOVE, X: 160, Y: 120, DX: 5, DY: 10, Scale: 100 / math.Hypot(90, -20),
+					Angle: -math.Atan2(-20, 90) * 180 / math.Pi, T: 15},
+				{Kind: TWOEND, T: 20},
+			},
+		},
+		{
+			// The remaining finger mustn't start rotating after one of the two is cancelled, and any third finger is
+			// ignored until they've all lifted
+			name: "cancelled touch",
+			events: []pointerEvent{
+				{"down", 1, 100, 100, 0},
+				{"down", 2, 200, 100, 5},
+				{"up", 2, 0, 0, 10},
+				{"move", 1, 150, 150, 15},
+				{"down", 3, 50, 50, 20},
+				{"move", 3, 60, 60, 25},
+				{"up", 3, 0, 0, 30},
+				{"up", 1, 0, 0, 35},
+				{"down", 4, 10, 10, 40},
+				{"up", 4, 0, 0, 45},
+			},
+			want: []gestureStep{
+				{Kind: ROTATESTART, X: 100, Y: 100, T: 0},
+				{Kind: ROTATEEND, T: 5},
+				{Kind: TWOSTART, X: 150, Y: 100, T: 5},
+				{Kind: TWOEND, T: 10},
+				{Kind: ROTATESTART, X: 10, Y: 10, T: 40},
+				{Kind: ROTATEEND, T: 45},
+			},
+		},
+		{
+			name: "unknown pointers",
+			events: []pointerEvent{
+				{"move", 7, 10, 10, 0},
+				{"up", 7, 0, 0, 5},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newGestureRecogniser()
+			var got []gestureStep
+			for _, e := range tc.events {
+				switch e.kind {
+				case "down":
+					got = append(got, g.Down(e.id, e.x, e.y, e.t)...)
+				case "move":
+					got = append(got, g.Move(e.id, e.x, e.y, e.t)...)
+				case "up":
+					got = append(got, g.Up(e.id, e.t)...)
+				}
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %d steps, want %d: %+v", len(got), len(tc.want), got)
+			}
+			for i := range got {
+				if !stepNear(got[i], tc.want[i]) {
+					t.Errorf("step %d = %+v, want %+v", i, got[i], tc.want[i])
+				}
+			}
+			if g.state != GESTUREIDLE || len(g.pointers) != 0 {
+				t.Errorf("recogniser not idle at the end: state %v, %d pointers", g.state, len(g.pointers))
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import "math"
+
+// The planes a grid can be drawn on.  These are bit flags, so any combination of them can be selected at once
+type GridPlane int
+
+const (
+	GRIDXY GridPlane = 1 << iota
+	GRIDXZ
+	GRIDYZ
+)
+
+const (
+	gridMajorColour = "rgb(200, 200, 200)"
+	gridMinorColour = "rgb(235, 235, 235)"
+)
+
+// Generates the grid objects for the selected planes, sized to suit the given domain.  The major grid lines line up
+// with the tick marks on the axes, and like them are spaced more closely as the user zooms in, and are limited to
+// those near the given point.  The minor lines are returned first, so the major lines are drawn over the top of them
+func generateGrid(planes GridPlane, d domain, zoom float64, c Point) []Object {
+	ext := axisExtents(d)
+	major := tickSpacing(ext, zoom)
+	minor := major / 5
+	if math.Round(major/math.Pow(10, math.Floor(math.Log10(major)))) == 2 {
+		minor = major / 4 // Keeps the minor lines on "nice" values, rather than at (say) 0.4
+	}
+
+	// Extend the grid out to the next major line past the data, then cut it down to the part the axes have ticks on
+	minX, maxX := tickWindow(math.Floor(ext.MinX/major)*major, math.Ceil(ext.MaxX/major)*major, major, c.X)
+	minY, maxY := tickWindow(math.Floor(ext.MinY/major)*major, math.Ceil(ext.MaxY/major)*major, major, c.Y)
+	minZ, maxZ := tickWindow(math.Floor(ext.MinZ/major)*major, math.Ceil(ext.MaxZ/major)*major, major, c.Z)
+
+	minorGrid := Object{C: gridMinorColour, Name: "grid"}
+	majorGrid := Object{C: gridMajorColour, Name: "grid"}
+	addLine := func(p1, p2 Point, v float64) {
+		o := &minorGrid
+		if isMultiple(v, major) {
+			o = &majorGrid
+		}
+		n := len(o.P)
+		o.P = append(o.P, p1, p2)
+		o.E = append(o.E, Edge{n, n + 1})
+	}
+
+	if planes&GRIDXY != 0 {
+		for _, v := range gridLines(minX, maxX, minor) {
+			addLine(Point{X: v, Y: minY}, Point{X: v, Y: maxY}, v)
+		}
+		for _, v := range gridLines(minY, maxY, minor) {
+			addLine(Point{X: minX, Y: v}, Point{X: maxX, Y: v}, v)
+		}
+	}
+	if planes&GRIDXZ != 0 {
+		for _, v := range gridLines(minX, maxX, minor) {
+			addLine(Point{X: v, Z: minZ}, Point{X: v, Z: maxZ}, v)
+		}
+		for _, v := range gridLines(minZ, maxZ, minor) {
+			addLine(Point{X: minX, Z: v}, Point{X: maxX, Z: v}, v)
+		}
+	}
+	if planes&GRIDYZ != 0 {
+		for _, v := range gridLines(minY, maxY, minor) {
+			addLine(Point{Y: v, Z: minZ}, Point{Y: v, Z: maxZ}, v)
+		}
+		for _, v := range gridLines(minZ, maxZ, minor) {
+			addLine(Point{Y: minY, Z: v}, Point{Y: maxY, Z: v}, v)
+		}
+	}
+	if len(minorGrid.P)+len(majorGrid.P) == 0 {
+		return nil
+	}
+	return []Object{minorGrid, majorGrid}
+}
+
+// Returns the positions of the grid lines between min and max (inclusive) at the given spacing
+func gridLines(min float64, max float64, spacing float64) (l []float64) {
+	for i := math.Round(min / spacing); i <= math.Round(max/spacing); i++ {
+		l = append(l, i*spacing)
+	}
+	return
+}
+
+// Returns true if v is (near enough to) a whole multiple of spacing
+func isMultiple(v float64, spacing float64) bool {
+	r := v / spacing
+	return math.Abs(r-math.Round(r)) < 1e-6
+}
@@ -0,0 +1,120 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Graphs an equation.  Equations with characters which aren't allowed are skipped
+func graphEquation(eq string) {
+	if err := validEquation(eq); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+	generateGraphAndDerives(eq)
+}
+
+// Outside of a browser, the graph is drawn into a PNG or SVG image instead of onto a canvas.  For example:
+//
+//	go build -o wasmGraph5 . && ./wasmGraph5 -eq "x^2" -view iso -o graph.png
+func main() {
+	eqFlag := flag.String("eq", eqStr, "The equation to graph")
+	outFlag := flag.String("o", "graph.png", "The file to write.  Files ending in .svg are written as SVG, others as PNG")
+	widthFlag := flag.Int("width", 1200, "Width of the image, in pixels")
+	heightFlag := flag.Int("height", 800, "Height of the image, in pixels")
+	viewFlag := flag.String("view", "top", "The view to draw: top, front, side, or iso")
+	macroFlag := flag.String("macro", "", "A macro script file to capture as an animation, into a .gif or .zip file")
+	paramFlag := flag.String("param", "", "A parameter of the equation to sweep as an animation, into a .gif or .zip file")
+	fromFlag := flag.Float64("from", -2, "The value to start the parameter sweep from")
+	toFlag := flag.Float64("to", 2, "The value to end the parameter sweep at")
+	framesFlag := flag.Int("frames", 21, "The number of frames in the parameter sweep")
+	fpsFlag := flag.Float64("fps", 20, "The frame rate of animations")
+	flag.Parse()
+
+	views := map[string]PresetView{"top": TOPVIEW, "front": FRONTVIEW, "side": SIDEVIEW, "iso": ISOVIEW}
+	v, ok := views[*viewFlag]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown view: %v\n", *viewFlag)
+		os.Exit(2)
+	}
+	var params []string
+	if *paramFlag != "" {
+		params = append(params, *paramFlag)
+	}
+	if err := validEquation(*eqFlag, params...); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	// Work out what to capture, if an animation was asked for.  A parameter sweep starts off graphing the equation with
+	// the first value of the parameter
+	var capture func(frame func(sc *scene) error) error
+	graphed := *eqFlag
+	switch {
+	case *macroFlag != "":
+		script, err := ioutil.ReadFile(*macroFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		m, err := parseMacro(string(script))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: bad macro script: %v\n", err)
+			os.Exit(1)
+		}
+		capture = func(frame func(sc *scene) error) error {
+			return captureMacro(m, *fpsFlag, frame)
+		}
+	case *paramFlag != "":
+		s := sweep{Equation: *eqFlag, Param: *paramFlag, From: *fromFlag, To: *toFlag, Frames: *framesFlag}
+		graphed = substituteParam(s.Equation, s.Param, s.From)
+		capture = func(frame func(sc *scene) error) error {
+			return captureSweep(s, frame)
+		}
+	}
+
+	// Graph the equation at the image size, then turn it to the requested view
+	width, height = float64(*widthFlag), float64(*heightFlag)
+	graphWidth, graphHeight = width*0.75, height-1
+	generateGraphAndDerives(graphed)
+	sceneLock.Lock()
+	viewMatrix = presetRotation(v)
+	updateAxes()
+	updateGrid()
+	opText = fmt.Sprintf("%s view.", *viewFlag)
+	publishScene()
+	sceneLock.Unlock()
+
+	// Write out the image
+	f, err := os.Create(*outFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	out := strings.ToLower(*outFlag)
+	switch {
+	case capture != nil && strings.HasSuffix(out, ".gif"):
+		err = encodeGIF(f, *widthFlag, *heightFlag, *fpsFlag, capture)
+	case capture != nil && strings.HasSuffix(out, ".zip"):
+		err = encodeZip(f, *widthFlag, *heightFlag, capture)
+	case capture != nil:
+		err = fmt.Errorf("animations can only be written to .gif or .zip files")
+	case strings.HasSuffix(out, ".svg"):
+		_, err = f.Write(renderSVG(currentScene(), width, height))
+	default:
+		err = renderPNG(f, currentScene(), *widthFlag, *heightFlag)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
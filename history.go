@@ -0,0 +1,125 @@
+package main
+
+import "sort"
+
+// A change of view, recorded so it can be undone and redone
+type historyEntry struct {
+	op     Operation
+	before matrix // The view matrix before the change
+	after  matrix // The view matrix after the change
+}
+
+const (
+	// Number of milliseconds taken to animate the undo, redo, reset, and saved view transitions
+	viewTransitionTime = 500
+)
+
+var (
+	undoStack []historyEntry
+	redoStack []historyEntry
+
+	// The saved views, by name
+	savedViews = make(map[string]matrix)
+)
+
+// Animates the view to a previously saved one.  Returns false if there's no saved view with the given name
+func recallView(name string) bool {
+	sceneLock.Lock()
+	m, ok := savedViews[name]
+	sceneLock.Unlock()
+	if ok {
+		submitOperation(viewOp(m, false))
+	}
+	return ok
+}
+
+// Records a mouse drag or touch gesture in the history, as a change from the view matrix when it started to the
+// current one.  Arcball drags and touch gestures change the view directly rather than going through the scheduler, so
+// they're also recorded in any macro being recorded as a change to the new view.  A different part of the graph may
+// be in view afterwards, so the axes and grid are regenerated to suit
+func recordDrag(direct bool) {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	op := viewOp(viewMatrix, false)
+	recordHistory(op, dragStartView, viewMatrix)
+	if matrixEqual(dragStartView, viewMatrix) {
+		return
+	}
+	if direct {
+		recordStep(op)
+	}
+	updateAxes()
+	updateGrid()
+	publishScene()
+}
+
+// Records a change of view in the history, so it can be undone.  Changes which didn't actually change the view aren't
+// worth recording, so they're skipped
+func recordHistory(op Operation, before matrix, after matrix) {
+	if op.noHistory || matrixEqual(before, after) {
+		return
+	}
+	undoStack = append(undoStack, historyEntry{op: op, before: before, after: after})
+	redoStack = nil
+}
+
+// Animates the view forward again, to how it was after the most recently undone change.  Any change still being
+// animated is stopped first, as it would otherwise be recorded once it finished, and throw away the changes to redo
+func redo() {
+	sceneLock.Lock()
+	stopOperations()
+	n := len(redoStack)
+	if n == 0 {
+		sceneLock.Unlock()
+		return
+	}
+	h := redoStack[n-1]
+	redoStack = redoStack[:n-1]
+	undoStack = append(undoStack, h)
+	sceneLock.Unlock()
+	submitOperation(viewOp(h.after, true))
+}
+
+// Animates the view back to the initial one
+func resetView() {
+	submitOperation(viewOp(identityMatrix, false))
+}
+
+// Saves the current view under the given name, replacing any existing view with that name
+func saveView(name string) {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	savedViews[name] = viewMatrix
+}
+
+// Returns the names of the saved views, in alphabetical order
+func savedViewNames() (names []string) {
+	for n := range savedViews {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return
+}
+
+// Animates the view back to how it was before the most recent change in the history.  Any change still being animated
+// is stopped first, recording how far it got, so that's what gets undone
+func undo() {
+	sceneLock.Lock()
+	stopOperations()
+	n := len(undoStack)
+	if n == 0 {
+		sceneLock.Unlock()
+		return
+	}
+	h := undoStack[n-1]
+	undoStack = undoStack[:n-1]
+	redoStack = append(redoStack, h)
+	sceneLock.Unlock()
+	submitOperation(viewOp(h.before, true))
+}
+
+// Returns a VIEW operation which animates to the given view matrix.  Operations for undo and redo aren't recorded in
+// the history themselves
+func viewOp(m matrix, noHistory bool) Operation {
+	return Operation{Op: VIEW, T: viewTransitionTime, E: EASEINOUT, M: m, noHistory: noHistory}
+}
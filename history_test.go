@@ -0,0 +1,90 @@
+package main
+
+import "testing"
+
+// Runs the animations until there's nothing left to animate
+func settle(c *manualClock) {
+	for i := 0; i < 1000; i++ {
+		stepAnimation(c.Now())
+		sceneLock.Lock()
+		done := anim == nil && len(pending) == 0
+		sceneLock.Unlock()
+		if done {
+			return
+		}
+		c.Advance(16)
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	c, restore := schedulerScene()
+	defer restore()
+	moveX := translate(identityMatrix, 1, 0, 0)
+	moveXY := translate(moveX, 0, 1, 0)
+	submitOperation(Operation{Op: TRANSLATE, T: 100, E: EASEINOUT, X: 1})
+	settle(c)
+	submitOperation(Operation{Op: TRANSLATE, T: 100, E: EASEINOUT, Y: 1})
+	settle(c)
+
+	steps := []struct {
+		name string
+		do   func()
+		want matrix
+	}{
+		{"undo", undo, moveX},
+		{"undo again", undo, identityMatrix},
+		{"undo with nothing to undo", undo, identityMatrix},
+		{"redo", redo, moveX},
+		{"redo again", redo, moveXY},
+		{"redo with nothing to redo", redo, moveXY},
+		{"undo after redoing", undo, moveX},
+	}
+	for _, s := range steps {
+		s.do()
+		settle(c)
+		if v := currentScene().view; !matrixNear(v, s.want) {
+			t.Fatalf("after %s, view = %v, want %v", s.name, v, s.want)
+		}
+	}
+
+	// A new change throws away the changes which could have been redone
+	submitOperation(Operation{Op: TRANSLATE, T: 100, E: EASEINOUT, Z: 1})
+	settle(c)
+	redo()
+	settle(c)
+	if v, want := currentScene().view, translate(moveX, 0, 0, 1); !matrixNear(v, want) {
+		t.Errorf("redo after a new change moved the view to %v, want %v", v, want)
+	}
+}
+
+func TestUndoDuringAnimation(t *testing.T) {
+	c, restore := schedulerScene()
+	defer restore()
+
+	// Undo part way through a change, while another is still waiting to be animated
+	submitOperation(Operation{Op: TRANSLATE, T: 1000, E: LINEAR, X: 2})
+	submitOperation(Operation{Op: ROTATE, T: 1000, E: LINEAR, Z: 90})
+	stepAnimation(c.Now())
+	c.Advance(500)
+	stepAnimation(c.Now())
+	undo()
+	settle(c)
+
+	// The undo sticks, rather than the change being recorded again once it would have finished
+	if v := currentScene().view; !matrixNear(v, identityMatrix) {
+		t.Fatalf("view after undoing is %v, want the starting view", v)
+	}
+	sceneLock.Lock()
+	nUndo, nRedo := len(undoStack), len(redoStack)
+	sceneLock.Unlock()
+	if nUndo != 0 || nRedo != 1 {
+		t.Fatalf("history has %d changes to undo and %d to redo, want 0 and 1", nUndo, nRedo)
+	}
+
+	// Redoing goes back to where the change had got to
+	redo()
+	settle(c)
+	if v, want := currentScene().view, translate(identityMatrix, 1, 0, 0); !matrixNear(v, want) {
+		t.Errorf("view after redoing is %v, want %v", v, want)
+	}
+}
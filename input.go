@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// The input map, which binds key combinations to actions.  Key combinations are written like "a", "ctrl+z", or
+// "shift+arrowleft".  The modifiers are ctrl (which includes the command key on macOS), alt, and shift.  Shift is left
+// out for printable keys pressed without another modifier, as the key itself already shows it (so "+" rather than
+// "shift+=")
+type inputConfig struct {
+	RotateStep float64           `json:"rotateStep"` // Degrees to rotate for each key press
+	PanStep    float64           `json:"panStep"`    // Fraction of the graph area to pan for each key press
+	ZoomStep   float64           `json:"zoomStep"`   // Scale factor to zoom in (or out) by for each key press
+	Time       int32             `json:"time"`       // Milliseconds taken to animate each key press
+	Keys       map[string]string `json:"keys"`       // Actions, by key combination.  An action of "none" unbinds the key
+}
+
+var (
+	// The input map in use.  This is protected by sceneLock
+	input = defaultInput()
+
+	// The actions keys can be bound to
+	actions = map[string]func(c inputConfig){
+		"cancel":             func(c inputConfig) { stopMacro(); cancelOperations() },
+		"undo":               func(c inputConfig) { undo() },
+		"redo":               func(c inputConfig) { redo() },
+		"reset-view":         func(c inputConfig) { resetView() },
+		"reset-pivot":        func(c inputConfig) { clearPivot() },
+		"top-view":           func(c inputConfig) { showPresetView(TOPVIEW) },
+		"front-view":         func(c inputConfig) { showPresetView(FRONTVIEW) },
+		"side-view":          func(c inputConfig) { showPresetView(SIDEVIEW) },
+		"iso-view":           func(c inputConfig) { showPresetView(ISOVIEW) },
+		"rotate-left":        func(c inputConfig) { rotateKey(c, 0, -1, 0) },
+		"rotate-right":       func(c inputConfig) { rotateKey(c, 0, 1, 0) },
+		"rotate-up":          func(c inputConfig) { rotateKey(c, -1, 0, 0) },
+		"rotate-down":        func(c inputConfig) { rotateKey(c, 1, 0, 0) },
+		"rotate-up-left":     func(c inputConfig) { rotateKey(c, -1, -1, 0) },
+		"rotate-up-right":    func(c inputConfig) { rotateKey(c, -1, 1, 0) },
+		"rotate-down-left":   func(c inputConfig) { rotateKey(c, 1, -1, 0) },
+		"rotate-down-right":  func(c inputConfig) { rotateKey(c, 1, 1, 0) },
+		"roll-left":          func(c inputConfig) { rotateKey(c, 0, 0, -1) },
+		"roll-right":         func(c inputConfig) { rotateKey(c, 0, 0, 1) },
+		"pan-left":           func(c inputConfig) { panKey(c, -1, 0) },
+		"pan-right":          func(c inputConfig) { panKey(c, 1, 0) },
+		"pan-up":             func(c inputConfig) { panKey(c, 0, -1) },
+		"pan-down":           func(c inputConfig) { panKey(c, 0, 1) },
+		"zoom-in":            func(c inputConfig) { submitOperation(zoomOp(c.ZoomStep, 0, 0)) },
+		"zoom-out":           func(c inputConfig) { submitOperation(zoomOp(1/c.ZoomStep, 0, 0)) },
+		"toggle-axes":        func(c inputConfig) { toggleObjects(&hideAxes) },
+		"toggle-grid":        func(c inputConfig) { toggleObjects(&hideGrid) },
+		"toggle-derivatives": func(c inputConfig) { toggleObjects(&hideDerivatives) },
+		"turntable":          func(c inputConfig) { toggleTurntable() },
+		"turntable-x":        func(c inputConfig) { setTurntableAxis(XAXIS) },
+		"turntable-y":        func(c inputConfig) { setTurntableAxis(YAXIS) },
+		"turntable-z":        func(c inputConfig) { setTurntableAxis(ZAXIS) },
+		"turntable-slower":   func(c inputConfig) { changeTurntableSpeed(1 / turntableSpeedStep) },
+		"turntable-faster":   func(c inputConfig) { changeTurntableSpeed(turntableSpeedStep) },
+		"toggle-perf":        func(c inputConfig) { togglePerf() },
+	}
+)
+
+// Returns the default input map
+func defaultInput() inputConfig {
+	return inputConfig{
+		RotateStep: 25,
+		PanStep:    0.1,
+		ZoomStep:   1.25,
+		Time:       250,
+		Keys: map[string]string{
+			"escape":           "cancel",
+			"ctrl+z":           "undo",
+			"ctrl+shift+z":     "redo",
+			"ctrl+y":           "redo",
+			"0":                "reset-view",
+			"insert":           "reset-view",
+			"c":                "reset-pivot",
+			"t":                "top-view",
+			"f":                "front-view",
+			"r":                "side-view",
+			"i":                "iso-view",
+			"arrowleft":        "rotate-left",
+			"a":                "rotate-left",
+			"4":                "rotate-left",
+			"arrowright":       "rotate-right",
+			"d":                "rotate-right",
+			"6":                "rotate-right",
+			"arrowup":          "rotate-up",
+			"w":                "rotate-up",
+			"8":                "rotate-up",
+			"arrowdown":        "rotate-down",
+			"s":                "rotate-down",
+			"2":                "rotate-down",
+			"7":                "rotate-up-left",
+			"home":             "rotate-up-left",
+			"9":                "rotate-up-right",
+			"pageup":           "rotate-up-right",
+			"1":                "rotate-down-left",
+			"end":              "rotate-down-left",
+			"3":                "rotate-down-right",
+			"pagedown":         "rotate-down-right",
+			"-":                "roll-left",
+			"+":                "roll-right",
+			"shift+arrowleft":  "pan-left",
+			"shift+arrowright": "pan-right",
+			"shift+arrowup":    "pan-up",
+			"shift+arrowdown":  "pan-down",
+			"e":                "zoom-in",
+			"q":                "zoom-out",
+			"g":                "toggle-grid",
+			"h":                "toggle-axes",
+			"v":                "toggle-derivatives",
+			"p":                "turntable",
+			"x":                "turntable-x",
+			"y":                "turntable-y",
+			"z":                "turntable-z",
+			"[":                "turntable-slower",
+			"]":                "turntable-faster",
+			"o":                "toggle-perf",
+		},
+	}
+}
+
+// Returns the key combination for a key press, in the form used by the input map
+func keyCombo(key string, ctrl bool, alt bool, shift bool) string {
+	key = strings.ToLower(key)
+	if len([]rune(key)) == 1 && !ctrl && !alt {
+		shift = false
+	}
+	var b strings.Builder
+	if ctrl {
+		b.WriteString("ctrl+")
+	}
+	if alt {
+		b.WriteString("alt+")
+	}
+	if shift {
+		b.WriteString("shift+")
+	}
+	b.WriteString(key)
+	return b.String()
+}
+
+// Loads an input map from a JSON config.  Anything the config leaves out keeps its current setting, and the key
+// bindings it gives are added to (or replace) the current ones
+func loadInput(config []byte) error {
+	var c inputConfig
+	if err := json.Unmarshal(config, &c); err != nil {
+		return err
+	}
+
+	// Check everything first, so a bad config doesn't leave the input map half changed
+	keys := make(map[string]string)
+	for k, a := range c.Keys {
+		combo, err := normaliseCombo(k)
+		if err != nil {
+			return err
+		}
+		if _, ok := actions[a]; !ok && a != "none" {
+			return fmt.Errorf("unknown action for key %s: %s", k, a)
+		}
+		keys[combo] = a
+	}
+	if c.ZoomStep < 0 || c.RotateStep < 0 || c.PanStep < 0 || c.Time < 0 {
+		return fmt.Errorf("step sizes and times can't be negative")
+	}
+
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	for k, a := range keys {
+		if a == "none" {
+			delete(input.Keys, k)
+			continue
+		}
+		input.Keys[k] = a
+	}
+	if c.RotateStep > 0 {
+		input.RotateStep = c.RotateStep
+	}
+	if c.PanStep > 0 {
+		input.PanStep = c.PanStep
+	}
+	if c.ZoomStep > 0 {
+		input.ZoomStep = c.ZoomStep
+	}
+	if c.Time > 0 {
+		input.Time = c.Time
+	}
+	return nil
+}
+
+// Returns a key combination from an input map config in the form keyCombo gives, so the modifiers can be written in
+// any order and case
+func normaliseCombo(s string) (string, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(s)), "+")
+	if n := len(parts); n > 1 && parts[n-1] == "" && parts[n-2] == "" {
+		// The key is "+" itself
+		parts = append(parts[:n-2], "+")
+	}
+	var ctrl, alt, shift bool
+	for _, p := range parts[:len(parts)-1] {
+		switch p {
+		case "ctrl", "control", "meta", "cmd":
+			ctrl = true
+		case "alt", "option":
+			alt = true
+		case "shift":
+			shift = true
+		default:
+			return "", fmt.Errorf("unknown modifier in key combination %s: %s", s, p)
+		}
+	}
+	key := parts[len(parts)-1]
+	if key == "" {
+		return "", fmt.Errorf("no key in key combination: %s", s)
+	}
+	return keyCombo(key, ctrl, alt, shift), nil
+}
+
+// Pans the graph by the key press pan step, in the given direction
+func panKey(c inputConfig, dx float64, dy float64) {
+	step := math.Min(graphWidth, graphHeight) * c.PanStep
+	submitOperation(panOp(dx*step, dy*step, c.Time))
+}
+
+// Carries out the action bound to a key combination.  Returns false if the key combination isn't bound to anything
+func performKey(combo string) bool {
+	sceneLock.Lock()
+	c := input
+	a, ok := input.Keys[combo]
+	sceneLock.Unlock()
+	if !ok {
+		return false
+	}
+
+	// Anything other than changing the turntable settings is the user interacting with the graph, so the turntable
+	// holds off for a while
+	if !strings.HasPrefix(a, "turntable") {
+		pauseTurntable()
+	}
+	actions[a](c)
+	return true
+}
+
+// Rotates the graph by the key press rotation step, in the given direction around each axis
+func rotateKey(c inputConfig, x float64, y float64, z float64) {
+	s := c.RotateStep
+	submitOperation(Operation{Op: ROTATE, T: c.Time, E: EASEINOUT, X: x * s, Y: y * s, Z: z * s})
+}
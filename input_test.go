@@ -0,0 +1,140 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKeyCombo(t *testing.T) {
+	tests := []struct {
+		key              string
+		ctrl, alt, shift bool
+		want             string
+	}{
+		{"a", false, false, false, "a"},
+		{"A", false, false, true, "a"},
+		{"+", false, false, true, "+"},
+		{"Z", true, false, true, "ctrl+shift+z"},
+		{"ArrowLeft", false, false, true, "shift+arrowleft"},
+		{"x", true, true, true, "ctrl+alt+shift+x"},
+		{"Escape", false, false, false, "escape"},
+	}
+	for _, tc := range tests {
+		if got := keyCombo(tc.key, tc.ctrl, tc.alt, tc.shift); got != tc.want {
+			t.Errorf("keyCombo(%q, %v, %v, %v) = %q, want %q", tc.key, tc.ctrl, tc.alt, tc.shift, got, tc.want)
+		}
+	}
+}
+
+func TestNormaliseCombo(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+		ok   bool
+	}{
+		{"z", "z", true},
+		{"Shift+Ctrl+Z", "ctrl+shift+z", true},
+		{"alt+shift+ctrl+x", "ctrl+alt+shift+x", true},
+		{"cmd+z", "ctrl+z", true},
+		{"Option+ArrowUp", "alt+arrowup", true},
+		{" ctrl+y ", "ctrl+y", true},
+		{"shift+a", "a", true},
+		{"+", "+", true},
+		{"ctrl++", "ctrl++", true},
+		{"ctrl+shift++", "ctrl+shift++", true},
+		{"ctrl+", "", false},
+		{"hyper+z", "", false},
+		{"", "", false},
+	}
+	for _, tc := range tests {
+		got, err := normaliseCombo(tc.s)
+		if (err == nil) != tc.ok {
+			t.Errorf("normaliseCombo(%q) error = %v, want ok %v", tc.s, err, tc.ok)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("normaliseCombo(%q) = %q, want %q", tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestLoadInput(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		ok     bool
+		keys   map[string]string // Bindings expected afterwards, with "" meaning unbound
+		rotate float64
+	}{
+		{
+			name:   "new bindings",
+			config: `{"rotateStep": 15, "keys": {"J": "rotate-left", "Shift+Ctrl+ArrowUp": "zoom-in", "ctrl++": "zoom-in"}}`,
+			ok:     true,
+			keys: map[string]string{"j": "rotate-left", "ctrl+shift+arrowup": "zoom-in", "ctrl++": "zoom-in",
+				"a": "rotate-left"},
+			rotate: 15,
+		},
+		{
+			name:   "unbinding",
+			config: `{"keys": {"a": "none"}}`,
+			ok:     true,
+			keys:   map[string]string{"a": "", "d": "rotate-right"},
+			rotate: 25,
+		},
+
+		// Nothing is changed by a config with anything wrong in it
+		{
+			name:   "unknown action",
+			config: `{"rotateStep": 15, "keys": {"j": "rotate-left", "k": "explode"}}`,
+			keys:   map[string]string{"j": "", "k": ""},
+			rotate: 25,
+		},
+		{
+			name:   "unknown modifier",
+			config: `{"keys": {"super+j": "rotate-left"}}`,
+			keys:   map[string]string{"j": ""},
+			rotate: 25,
+		},
+		{
+			name:   "negative step",
+			config: `{"rotateStep": -5, "keys": {"j": "rotate-left"}}`,
+			keys:   map[string]string{"j": ""},
+			rotate: 25,
+		},
+		{
+			name:   "bad JSON",
+			config: `{"keys": {"j": "rotate-left"`,
+			keys:   map[string]string{"j": ""},
+			rotate: 25,
+		},
+	}
+	defer func() {
+		sceneLock.Lock()
+		defer sceneLock.Unlock()
+		input = defaultInput()
+	}()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sceneLock.Lock()
+			input = defaultInput()
+			sceneLock.Unlock()
+			err := loadInput([]byte(tc.config))
+			if (err == nil) != tc.ok {
+				t.Fatalf("loadInput() error = %v, want ok %v", err, tc.ok)
+			}
+
+			sceneLock.Lock()
+			defer sceneLock.Unlock()
+			got := make(map[string]string)
+			for k := range tc.keys {
+				got[k] = input.Keys[k]
+			}
+			if !reflect.DeepEqual(got, tc.keys) {
+				t.Errorf("bindings are %v, want %v", got, tc.keys)
+			}
+			if input.RotateStep != tc.rotate {
+				t.Errorf("rotate step is %v, want %v", input.RotateStep, tc.rotate)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+// Stops the browser acting on key presses which are bound to an action in the graph's input map, such as ctrl+z
+// (undo in a focused text box) or the arrow keys (scrolling the page).  Go callbacks only run once the browser has
+// finished handling the event, which is too late to prevent its default action, so this is done in javascript.
+// boundKeys holds the bound key combinations, and is kept up to date from Go (publishBoundKeys in browser.go)
+var boundKeys = new Set();
+
+// Returns the key combination for a key press, the same way as keyCombo in input.go
+function keyCombo(event) {
+    const key = event.key.toLowerCase();
+    const ctrl = event.ctrlKey || event.metaKey;
+    const shift = event.shiftKey && !([...key].length === 1 && !ctrl && !event.altKey);
+    return (ctrl ? "ctrl+" : "") + (event.altKey ? "alt+" : "") + (shift ? "shift+" : "") + key;
+}
+
+// Keydown handler, preventing the default action of bound keys
+function preventBoundKeys(event) {
+    if (boundKeys.has(keyCombo(event))) {
+        event.preventDefault();
+    }
+}
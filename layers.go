@@ -0,0 +1,106 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"strconv"
+	"syscall/js"
+)
+
+// A layer of the picture which only changes now and then.  It's drawn onto its own offscreen canvas, which is copied
+// onto the main canvas each frame until something on it changes
+type canvasLayer struct {
+	el          js.Value
+	r           Renderer
+	key         string
+	w, h, ratio float64
+}
+
+var (
+	// The layers the frames are put together from: the graph area, and the information area on top of it
+	graphLayer, infoLayer *canvasLayer
+
+	// The scene last drawn with WebGL
+	glScene *scene
+)
+
+// Returns a renderer for a canvas 2D context, batching the drawing up the same way as for the main canvas
+func canvasRendererFor(ctx js.Value) Renderer {
+	if _, ok := canvas.(*batchRenderer); ok {
+		return newBatchRenderer(ctx)
+	}
+	return &canvasRenderer{ctx: ctx}
+}
+
+// Draws a frame onto the main canvas, from its layers.  Only the layers whose contents have changed are redrawn
+func drawFrame(sc *scene, w float64, h float64) {
+	if graphLayer == nil {
+		graphLayer, infoLayer = newCanvasLayer(), newCanvasLayer()
+	}
+	if glRenderer != nil && sc != glScene {
+		glRenderer.draw(sc, w, h, pixelRatio)
+		glScene = sc
+	}
+	ctx := jsCall(canvasEl, "getContext", "2d")
+	jsCall(ctx, "clearRect", 0, 0, w, h)
+
+	// With WebGL, the geometry is drawn underneath on the WebGL canvas, so the graph layer only has the labels.  The
+	// key changes when WebGL is given up on, so the geometry is then drawn into the layer
+	key := strconv.FormatUint(sc.gen, 10)
+	if glRenderer != nil {
+		key += "gl"
+	}
+	graphLayer.drawOnto(ctx, key, w, h, func(r Renderer) {
+		if glRenderer == nil {
+			r.SetFillStyle("white")
+			r.FillRect(0, 0, w, h)
+			drawGeometry(r, sc, w, h)
+		}
+		drawLabels(r, sc, w, h)
+	})
+	sceneLock.Lock()
+	box, boxX, boxY := zoomBoxActive, zoomBoxX, zoomBoxY
+	sceneLock.Unlock()
+	if box {
+		drawZoomBox(canvas, boxX, boxY)
+		flushRenderer(canvas)
+	}
+	parts := INFOSCREEN
+	if highLightSource {
+		parts |= INFOHIGHLIGHT
+	}
+	infoLayer.drawOnto(ctx, infoKey(sc, parts), w, h, func(r Renderer) {
+		drawInfo(r, sc, w, h, parts)
+	})
+}
+
+// Copies the layer onto a canvas 2D context.  If the key, size, or pixel ratio has changed since it was last drawn, the
+// layer is redrawn first with the given function
+func (l *canvasLayer) drawOnto(ctx js.Value, key string, w float64, h float64, draw func(r Renderer)) {
+	if key != l.key || w != l.w || h != l.h || pixelRatio != l.ratio {
+		if w != l.w || h != l.h || pixelRatio != l.ratio {
+			// Resizing a canvas clears it
+			sizeCanvas2D(l.el, w, h, pixelRatio)
+		} else {
+			jsCall(jsCall(l.el, "getContext", "2d"), "clearRect", 0, 0, w, h)
+		}
+		draw(l.r)
+		flushRenderer(l.r)
+		l.key, l.w, l.h, l.ratio = key, w, h, pixelRatio
+	}
+	jsCall(ctx, "drawImage", l.el, 0, 0, w, h)
+}
+
+// Draws anything batched up for a canvas
+func flushRenderer(r Renderer) {
+	if b, ok := r.(*batchRenderer); ok {
+		b.Flush()
+	}
+}
+
+// Returns a new, empty layer
+func newCanvasLayer() *canvasLayer {
+	el := doc.Call("createElement", "canvas")
+	return &canvasLayer{el: el, r: canvasRendererFor(el.Call("getContext", "2d"))}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// A recorded sequence of operations, which can be saved as a JSON script and played back later.  Playback starts by
+// graphing the equation and animating to the view which were in place when the recording started
+type macro struct {
+	Equation string      `json:"equation"`
+	View     matrix      `json:"view"`
+	Steps    []macroStep `json:"steps"`
+}
+
+// One operation in a macro
+type macroStep struct {
+	Wait float64   `json:"wait"` // Number of milliseconds to wait after the previous step, before starting this one
+	Op   Operation `json:"op"`
+}
+
+// The state of a macro being played back
+type macroPlayer struct {
+	m       macro
+	next    int     // The step to play next
+	due     float64 // The frame time the next step is due, or -1 if the previous step has only just been played
+	waiting bool    // True while an equation step is being graphed
+}
+
+var (
+	// The macro being recorded, and the frame time of the most recently recorded step
+	recording  *macro
+	recordTime float64
+
+	// The macro being played back
+	player *macroPlayer
+
+	operationNames = map[OperationType]string{
+		ROTATE:    "rotate",
+		SCALE:     "scale",
+		TRANSLATE: "translate",
+		ZOOM:      "zoom",
+		VIEW:      "view",
+		EQUATION:  "equation",
+	}
+	easingNames = map[Easing]string{
+		LINEAR:    "linear",
+		EASEINOUT: "easeinout",
+		SPRING:    "spring",
+	}
+)
+
+// Returns the name of the easing function, as used in macro scripts
+func (e Easing) MarshalText() ([]byte, error) {
+	if n, ok := easingNames[e]; ok {
+		return []byte(n), nil
+	}
+	return nil, fmt.Errorf("unknown easing function: %d", e)
+}
+
+// Returns the name of the operation type, as used in macro scripts
+func (o OperationType) MarshalText() ([]byte, error) {
+	if n, ok := operationNames[o]; ok {
+		return []byte(n), nil
+	}
+	return nil, fmt.Errorf("unknown operation type: %d", o)
+}
+
+// Sets the easing function from its name in a macro script
+func (e *Easing) UnmarshalText(text []byte) error {
+	for k, n := range easingNames {
+		if strings.EqualFold(n, string(text)) {
+			*e = k
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown easing function: %s", text)
+}
+
+// Sets the operation type from its name in a macro script
+func (o *OperationType) UnmarshalText(text []byte) error {
+	for k, n := range operationNames {
+		if strings.EqualFold(n, string(text)) {
+			*o = k
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown operation type: %s", text)
+}
+
+// Parses a macro script.  Scripts with fields or operations this doesn't know about are rejected, rather than quietly
+// ignoring parts of them, as are steps which would collapse the view
+func parseMacro(script string) (m macro, err error) {
+	dec := json.NewDecoder(strings.NewReader(script))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(&m); err != nil {
+		return
+	}
+	if len(m.View) != 16 {
+		m.View = identityMatrix
+	}
+	if err = validEquation(m.Equation); err != nil {
+		return
+	}
+	for i, s := range m.Steps {
+		if err = validStep(s.Op); err != nil {
+			return m, fmt.Errorf("step %d: %v", i+1, err)
+		}
+	}
+	return
+}
+
+// Starts playing back a macro, stopping any recording or playback already in progress
+func playMacro(m macro) {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+
+	// Put the equation and view back the way they were when the recording started, then play the recorded steps
+	var steps []macroStep
+	if m.Equation != "" && m.Equation != graphEq {
+		steps = append(steps, macroStep{Op: Operation{Op: EQUATION, Eq: m.Equation}})
+	}
+	steps = append(steps, macroStep{Op: viewOp(m.View, false)})
+	m.Steps = append(steps, m.Steps...)
+
+	recording = nil
+	pending = nil
+	player = &macroPlayer{m: m, due: -1}
+}
+
+// Records a step in the macro being recorded, if there is one.  The scene lock must be held by the caller
+func recordStep(op Operation) {
+	if recording == nil {
+		return
+	}
+	now := clock.Now()
+	op.noHistory = false
+	recording.Steps = append(recording.Steps, macroStep{Wait: now - recordTime, Op: op})
+	recordTime = now
+}
+
+// Starts recording a new macro, from the current equation and view
+func startRecording() {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	player = nil
+	recording = &macro{Equation: graphEq, View: viewMatrix}
+	recordTime = clock.Now()
+	opText = "Recording macro."
+	publishScene()
+}
+
+// Plays the next step of the macro being played back, if it's due by the given frame time.  Equation steps are
+// graphed in the background, and the macro carries on once they're done
+func stepMacro(now float64) {
+	sceneLock.Lock()
+	p := player
+	if p == nil || p.waiting {
+		sceneLock.Unlock()
+		return
+	}
+	if p.next >= len(p.m.Steps) {
+		player = nil
+		sceneLock.Unlock()
+		return
+	}
+	s := p.m.Steps[p.next]
+	if p.due < 0 {
+		p.due = now + s.Wait
+	}
+	if now < p.due {
+		sceneLock.Unlock()
+		return
+	}
+	p.next++
+	p.due = -1
+	if s.Op.Op == EQUATION {
+		p.waiting = true
+	}
+	sceneLock.Unlock()
+
+	if s.Op.Op != EQUATION {
+		submitOperation(s.Op)
+		return
+	}
+	go func() {
+		graphEquation(s.Op.Eq)
+		sceneLock.Lock()
+		p.waiting = false
+		sceneLock.Unlock()
+	}()
+}
+
+// Stops any macro playback in progress
+func stopMacro() {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	player = nil
+}
+
+// Stops recording, returning the recorded macro as a JSON script
+func stopRecording() string {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	if recording == nil {
+		return ""
+	}
+	j, err := json.MarshalIndent(recording, "", "  ")
+	recording = nil
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return ""
+	}
+	return string(j)
+}
+
+// Returns an error if a macro step can't be played back.  Scale factors have to be positive, as scaling by zero
+// collapses the view matrix, and a negative one turns the graph inside out
+func validStep(op Operation) error {
+	finite := func(v ...float64) bool {
+		for _, f := range v {
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				return false
+			}
+		}
+		return true
+	}
+	if op.T < 0 || !finite(op.X, op.Y, op.Z, op.S) {
+		return fmt.Errorf("invalid %s step", operationNames[op.Op])
+	}
+	switch op.Op {
+	case SCALE:
+		if op.X <= 0 || op.Y <= 0 || op.Z <= 0 {
+			return fmt.Errorf("scale step with a scale factor which isn't positive")
+		}
+	case ZOOM:
+		if op.S <= 0 {
+			return fmt.Errorf("zoom step with a scale factor which isn't positive")
+		}
+	case VIEW:
+		if len(op.M) != 16 || !finite(op.M...) {
+			return fmt.Errorf("view step with an invalid matrix")
+		}
+	case EQUATION:
+		return validEquation(op.Eq)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMacroRoundTrip(t *testing.T) {
+	m := macro{
+		Equation: "x^2",
+		View:     presetRotation(ISOVIEW),
+		Steps: []macroStep{
+			{Wait: 100, Op: Operation{Op: ROTATE, T: 250, E: EASEINOUT, Z: 25}},
+			{Wait: 0, Op: Operation{Op: SCALE, T: 500, E: LINEAR, X: 2, Y: 1, Z: 0.5}},
+			{Wait: 50, Op: Operation{Op: TRANSLATE, T: 250, E: EASEINOUT, X: 1.5, Y: -2}},
+			{Wait: 20, Op: Operation{Op: ZOOM, T: 250, E: SPRING, S: 1.25, X: 0.5}},
+			{Wait: 10, Op: Operation{Op: VIEW, T: 500, E: EASEINOUT, M: presetRotation(TOPVIEW)}},
+			{Wait: 300, Op: Operation{Op: EQUATION, Eq: "x^3 - 2*x"}},
+		},
+	}
+	j, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseMacro(string(j))
+	if err != nil {
+		t.Fatalf("parseMacro() of a saved macro failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("parseMacro() = %+v, want %+v", got, m)
+	}
+}
+
+func TestParseMacroRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{"bad JSON", `{"steps": [`},
+		{"unknown field", `{"steps": [], "speed": 2}`},
+		{"unknown step field", `{"steps": [{"wait": 0, "op": {"op": "rotate", "z": 25, "E": "spring"}}]}`},
+		{"unknown operation", `{"steps": [{"op": {"op": "spin", "z": 25}}]}`},
+		{"unknown easing", `{"steps": [{"op": {"op": "rotate", "easing": "bouncy", "z": 25}}]}`},
+		{"zoom without a scale factor", `{"steps": [{"op": {"op": "zoom", "t": 300, "x": 1}}]}`},
+		{"zoom by a negative scale factor", `{"steps": [{"op": {"op": "zoom", "s": -2}}]}`},
+		{"scale without scale factors", `{"steps": [{"op": {"op": "scale"}}]}`},
+		{"scale by zero", `{"steps": [{"op": {"op": "scale", "x": 2, "y": 0, "z": 2}}]}`},
+		{"negative duration", `{"steps": [{"op": {"op": "rotate", "t": -5, "z": 25}}]}`},
+		{"view without a matrix", `{"steps": [{"op": {"op": "view"}}]}`},
+		{"bad equation", `{"steps": [{"op": {"op": "equation", "eq": "alert(1)"}}]}`},
+		{"bad starting equation", `{"equation": "x; rm", "steps": []}`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parseMacro(tc.script); err == nil {
+				t.Errorf("parseMacro(%s) didn't fail", tc.script)
+			}
+		})
+	}
+}
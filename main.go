@@ -1,5 +1,5 @@
 //Wasming
-// compile: GOOS=js GOARCH=wasm go build -o main.wasm ./main.go
+// compile: GOOS=js GOARCH=wasm go build -o main.wasm .
 package main
 
 import (
@@ -8,11 +8,9 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
-	"syscall/js"
 	"time"
 
 	eq "github.com/corywalker/expreduce/expreduce"
-	"go.uber.org/atomic"
 )
 
 type matrix []float64
@@ -43,15 +41,25 @@ const (
 	ROTATE OperationType = iota
 	SCALE
 	TRANSLATE
+	ZOOM     // Scale by S, then translate by X, Y, and Z.  Used for zooming in on a point other than the origin
+	VIEW     // Change to the view matrix M, regardless of the current view
+	EQUATION // Graph the equation Eq.  These aren't animated, so are only used in macro scripts
 )
 
+// A change to the view (or for EQUATION, to the graph).  The fields are exported, so operations can be saved in and
+// loaded from macro scripts
 type Operation struct {
-	op OperationType
-	t  int32 // Number of milliseconds the operation should take
-	f  int32 // Number of display frames the operation should be broken into
-	X  float64
-	Y  float64
-	Z  float64
+	Op OperationType `json:"op"`
+	T  int32         `json:"t"`      // Number of milliseconds the operation should take
+	E  Easing        `json:"easing"` // The easing function used to animate the operation
+	X  float64       `json:"x"`
+	Y  float64       `json:"y"`
+	Z  float64       `json:"z"`
+	S  float64       `json:"s,omitempty"`
+	M  matrix        `json:"m,omitempty"`
+	Eq string        `json:"eq,omitempty"`
+
+	noHistory bool // If true, the operation isn't recorded in the undo history
 }
 
 const (
@@ -65,50 +73,10 @@ var (
 	//eqStr = "(x^3)/2"
 	//eqStr = "(3/2)*x^2"
 
-	// The empty world space
+	// The empty world space.  Like the view matrix, changes to it need the scene lock held, and are only seen by the
+	// renderer once published in a scene snapshot
 	worldSpace []Object
 
-	// The point objects
-	axes = Object{
-		C:    "grey",
-		Name: "axes",
-		P: []Point{
-			{X: -0.1, Y: 0.1, Z: 0.0},
-			{X: -0.1, Y: 10, Z: 0.0},
-			{X: 0.1, Y: 10, Z: 0.0},
-			{X: 0.1, Y: 0.1, Z: 0.0},
-			{X: 10, Y: 0.1, Z: 0.0},
-			{X: 10, Y: -0.1, Z: 0.0},
-			{X: 0.1, Y: -0.1, Z: 0.0},
-			{X: 0.1, Y: -10, Z: 0.0},
-			{X: -0.1, Y: -10, Z: 0.0},
-			{X: -0.1, Y: -0.1, Z: 0.0},
-			{X: -10, Y: -0.1, Z: 0.0},
-			{X: -10, Y: 0.1, Z: 0.0},
-			{X: 10, Y: -1.0, Z: 0.0, Label: "X", LabelAlign: "center"},
-			{X: -10, Y: -1.0, Z: 0.0, Label: "-X", LabelAlign: "center"},
-			{X: 0.0, Y: 10.5, Z: 0.0, Label: "Y", LabelAlign: "center"},
-			{X: 0.0, Y: -11, Z: 0.0, Label: "-Y", LabelAlign: "center"},
-		},
-		E: []Edge{
-			{0, 1},
-			{1, 2},
-			{2, 3},
-			{3, 4},
-			{4, 5},
-			{5, 6},
-			{6, 7},
-			{7, 8},
-			{8, 9},
-			{9, 10},
-			{10, 11},
-			{11, 0},
-		},
-		S: []Surface{
-			{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
-		},
-	}
-
 	// The 4x4 identity matrix
 	identityMatrix = matrix{
 		1, 0, 0, 0,
@@ -117,141 +85,76 @@ var (
 		0, 0, 0, 1,
 	}
 
-	// Initialise the transform matrix with the identity matrix
-	transformMatrix = identityMatrix
-
-	// FIFO queue
-	queue        chan Operation
-	renderActive *atomic.Bool
-
-	width, height       float64
-	graphWidth          float64
-	graphHeight         float64
-	cCall, kCall, mCall js.Callback
-	rCall, wCall        js.Callback
-	ctx, doc            js.Value
-	btnEl, canvasEl     js.Value
-	derivStr            string
-	opText              string
-	highLightSource     bool
-	pointStep           = 0.05
-	debug               = false // If true, some debugging info is printed to the javascript console
+	// The accumulation of every transformation applied to the world space so far.  The objects in world space are left
+	// as they are, and this is applied to their points as each frame is drawn
+	viewMatrix = identityMatrix
+
+	// The range of world space covered by the equation and its derivatives, before any transformations
+	graphDomain domain
+
+	// The planes to draw the grid on
+	gridPlanes = GRIDXY
+
+	// The equation currently graphed
+	graphEq string
+
+	// The size of the page and of the graph area.  These are only changed with sceneLock held, as the pivot point is
+	// worked out from them by macros and captures running in other goroutines.  The javascript callbacks all run one
+	// at a time on the same goroutine, so they can read them without it
+	width, height float64
+	graphWidth    float64
+	graphHeight   float64
+
+	// The state of any mouse drag in progress.  These are protected by sceneLock, as the turntable and the frame
+	// renderer check them too
+	panActive          bool
+	dragStartView      matrix // The view matrix when the current pan or arcball drag started, for the history
+	panLastX, panLastY float64
+	zoomBoxActive      bool
+	zoomBoxX, zoomBoxY [2]float64 // The corners of the zoom box, in canvas co-ordinates
+
+	canvas          Renderer
+	derivStr        string
+	opText          string
+	highLightSource bool
+	lastFrameTime   float64
+	gestures        = newGestureRecogniser() // Turns touch screen pointer events into gestures
+	pointStep       = 0.05
 )
 
-func main() {
-	// Initialise canvas
-	doc = js.Global().Get("document")
-	canvasEl = doc.Call("getElementById", "mycanvas")
-	width = doc.Get("body").Get("clientWidth").Float()
-	height = doc.Get("body").Get("clientHeight").Float()
-	canvasEl.Call("setAttribute", "width", width)
-	canvasEl.Call("setAttribute", "height", height)
-	canvasEl.Set("tabIndex", 0) // Not sure if this is needed
-	ctx = canvasEl.Call("getContext", "2d")
-
-	// Set up handler for clicks on the "Graph it" button
-	btnEl = doc.Call("getElementById", "update")
-	btnCall := js.NewCallback(buttonHandler)
-	btnEl.Call("addEventListener", "click", btnCall)
-	defer btnCall.Release()
-
-	// Set up the mouse click handler
-	cCall = js.NewCallback(clickHandler)
-	canvasEl.Call("addEventListener", "mousedown", cCall)
-	defer cCall.Release()
-
-	// Set up the keypress handler
-	renderActive = atomic.NewBool(false)
-	kCall = js.NewCallback(keypressHandler)
-	canvasEl.Call("addEventListener", "keydown", kCall)
-	defer kCall.Release()
-
-	// Set up the mouse move handler
-	mCall = js.NewCallback(moveHandler)
-	canvasEl.Call("addEventListener", "mousemove", mCall)
-	defer mCall.Release()
-
-	// Set the frame renderer going
-	rCall = js.NewCallback(renderFrame)
-	js.Global().Call("requestAnimationFrame", rCall)
-	defer rCall.Release()
-
-	// Set up the mouse wheel handler
-	wCall = js.NewCallback(wheelHandler)
-	canvasEl.Call("addEventListener", "wheel", wCall)
-	defer wCall.Release()
-
-	// Set the operations processor going
-	queue = make(chan Operation)
-	go processOperations(queue)
-
-	// Create the graph objects for the equation and its derivative
-	generateGraphAndDerives(eqStr)
-
-	// Keep the application running
-	done := make(chan struct{}, 0)
-	<-done
-}
-
-// Simple handler for mouse click events on the "Graph it" button
-func buttonHandler(args []js.Value) {
-	// Retrieve the new equation for graphing
-	equationEl := doc.Call("getElementById", "equation")
-	newEq := equationEl.Get("value").String()
-	if debug {
-		fmt.Printf("%v\n", newEq)
-	}
-
-	// Input validation
-	errEl := doc.Call("getElementById", "errmsg")
-	charEl := doc.Call("getElementById", "errchars")
+// Applies a transformation matrix to the view of the world space, optionally around the pivot point.  If an animation
+// is in progress, it's applied to the animation's start and end points as well, so the animation doesn't undo it
+func applyTransform(m matrix, aroundPivot bool) {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	if aroundPivot {
+		m = aroundPoint(m, pivotView(viewMatrix))
+	}
+	if anim != nil {
+		anim.start = matrixMult(m, anim.start)
+		anim.end = matrixMult(m, anim.end)
+		anim.rel = matrixMult(anim.end, invertMatrix(anim.start))
+	}
+	viewMatrix = matrixMult(m, viewMatrix)
+	publishScene()
+}
+
+// Returns the characters of an equation which aren't allowed in one, or an empty string if they're all fine.  The
+// given parameter names are allowed too, as whole words, for the equations of parameter sweeps
+func badEquationChars(eq string, params ...string) string {
+	for _, p := range params {
+		eq = regexp.MustCompile(`\b`+regexp.QuoteMeta(p)+`\b`).ReplaceAllLiteralString(eq, "")
+	}
 	var badChars strings.Builder
-	for _, j := range newEq {
+	for _, j := range eq {
 		switch j {
 		case 'x', '+', '-', '*', '/', '^', ' ', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', '(', ')', '[', ']':
 			// The character is valid
 		default:
-			badChars.WriteString(string(j))
-		}
-	}
-	if badChars.String() != "" {
-		// Display error message
-		errEl.Set("style", "display: block;")
-		charEl.Set("innerHTML", badChars.String())
-		if debug {
-			fmt.Printf("Bad characters: %s\n", badChars.String())
-		}
-		return
-	}
-
-	// Clear any existing error message
-	errEl.Set("style", "display: none;")
-	charEl.Set("innerHTML", "")
-
-	// Create new graph and derivative objects
-	generateGraphAndDerives(newEq)
-}
-
-// Simple mouse handler watching for people clicking on the source code link
-func clickHandler(args []js.Value) {
-	event := args[0]
-	clientX := event.Get("clientX").Float()
-	clientY := event.Get("clientY").Float()
-	if debug {
-		fmt.Printf("ClientX: %v  clientY: %v\n", clientX, clientY)
-		if clientX > graphWidth && clientY > (height-40) {
-			println("URL hit!")
-		}
-	}
-
-	// If the user clicks the source code URL area, open the URL
-	if clientX > graphWidth && clientY > (height-40) {
-		w := js.Global().Call("open", sourceURL)
-		if w == js.Null() {
-			// Couldn't open a new window, so try loading directly in the existing one instead
-			doc.Set("location", sourceURL)
+			badChars.WriteRune(j)
 		}
 	}
+	return badChars.String()
 }
 
 // Returns the colour to use for a derivative
@@ -270,12 +173,15 @@ func colDeriv(i int) string {
 
 // Generates the graph and derivatives for a given equation
 func generateGraphAndDerives(newEq string) {
-	// Initialise the transform matrix with the identity matrix
-	transformMatrix = identityMatrix
+	graphed := newEq
+	start := time.Now()
 
-	// Add the X/Y axes object to the world space
-	worldSpace = []Object{}
-	worldSpace = append(worldSpace, importObject(axes, 0.0, 0.0, 0.0))
+	// The new objects are put together separately, then swapped into the world space in one go at the end.  The
+	// calculations can take a while, and this way the existing graph is still drawn properly in the meantime
+	var objs []Object
+
+	// Add a placeholder for the axes object.  It's generated once the domain of the graphs is known
+	objs = append(objs, Object{Name: "axes"})
 
 	// Create a graph object with the main data points on it
 	var graph Object
@@ -314,7 +220,7 @@ func generateGraphAndDerives(newEq string) {
 	}
 	graph.Name = "Equation"
 	graph.Eq = fmt.Sprintf("y = %s", mathFormat(newEq))
-	worldSpace = append(worldSpace, importObject(graph, 0.0, 0.0, 0.0))
+	objs = append(objs, importObject(graph, 0.0, 0.0, 0.0))
 
 	// Graph the derivatives of the equation
 	derivNum := 1
@@ -347,9 +253,6 @@ func generateGraphAndDerives(newEq string) {
 			derivResult = derivExpr.Eval(derivState)
 			derivResult = derivState.ProcessTopLevelResult(derivExpr, derivResult)
 			tmp := derivResult.StringForm(eq.ActualStringFormArgsFull("OutputForm", derivState))
-			if debug {
-				fmt.Printf("Val: %0.2f Derivative String: %v Result: %v\n", x, derivStr, tmp)
-			}
 			y, err := strconv.ParseFloat(tmp, 64)
 			if err != nil {
 				y = -1 // Set this to -1 to visually indicate something went wrong
@@ -366,9 +269,6 @@ func generateGraphAndDerives(newEq string) {
 					slopeP2 = math.Round(y*10000) / 10000
 					riseOverRun := (slopeP2 - slopeP1) / pointStep
 					slope = math.Round(riseOverRun*10000) / 10000
-					if debug {
-						fmt.Printf("Slope: (%v - %v) / %v = %v\n", slopeP2, slopeP1, pointStep, slope)
-					}
 					slopeP1 = slopeP2
 					gotSlope = true
 				}
@@ -376,16 +276,10 @@ func generateGraphAndDerives(newEq string) {
 				slopeP2 = math.Round(y*10000) / 10000
 				riseOverRun := (slopeP2 - slopeP1) / pointStep
 				slope2 = math.Round(riseOverRun*10000) / 10000
-				if debug {
-					fmt.Printf("Slope2: (%v - %v) / %v = %v", slopeP2, slopeP1, pointStep, slope2)
-				}
 				slopeP1 = slopeP2
 				if slope != slope2 {
 					straightLine = false
 				}
-				if debug {
-					fmt.Printf(" Straight line: %v\n", straightLine)
-				}
 			}
 
 			p = Point{X: x, Y: y}
@@ -403,10 +297,33 @@ func generateGraphAndDerives(newEq string) {
 		}
 		deriv.Name = fmt.Sprintf("%s order derivative", strDeriv(derivNum))
 		deriv.Eq = fmt.Sprintf("y = %s", mathFormat(derivStr))
-		worldSpace = append(worldSpace, importObject(deriv, 0.0, 0.0, 0.0))
+		objs = append(objs, importObject(deriv, 0.0, 0.0, 0.0))
 		newEq = derivStr
 		derivNum++
 	}
+
+	recordEval(float64(time.Since(start)) / float64(time.Millisecond))
+
+	// Swap the new objects into the world space, resetting the view and dropping any operations still in progress.  The
+	// views in the history were of a different graph, so a new equation clears it
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	anim = nil
+	pending = nil
+	viewMatrix = identityMatrix
+	worldSpace = objs
+	if graphed != graphEq {
+		undoStack, redoStack = nil, nil
+	}
+	graphEq = graphed
+	pivotSet = false
+
+	// Generate the axes, grid, and pivot point to suit the new graphs
+	graphDomain = objectDomain(worldSpace[1:])
+	updateAxes()
+	updateGrid()
+	updatePivot()
+	publishScene()
 }
 
 // Returns an object whose points have been transformed into 3D world space XYZ co-ordinates.  Also assigns a number
@@ -447,41 +364,91 @@ func importObject(ob Object, x float64, y float64, z float64) (translatedObject
 	return translatedObject
 }
 
-// Simple keyboard handler for catching the arrow, WASD, and numpad keys
-// Key value info can be found here: https://developer.mozilla.org/en-US/docs/Web/API/KeyboardEvent/key/Key_Values
-func keypressHandler(args []js.Value) {
-	event := args[0]
-	key := event.Get("key").String()
-	if debug {
-		fmt.Printf("Key is: %v\n", key)
-	}
-
-	// Don't add operations if one is already in progress
-	stepSize := float64(25)
-	if !renderActive.Load() {
-		switch key {
-		case "ArrowLeft", "a", "A", "4":
-			queue <- Operation{op: ROTATE, t: 50, f: 12, X: 0, Y: -stepSize, Z: 0}
-		case "ArrowRight", "d", "D", "6":
-			queue <- Operation{op: ROTATE, t: 50, f: 12, X: 0, Y: stepSize, Z: 0}
-		case "ArrowUp", "w", "W", "8":
-			queue <- Operation{op: ROTATE, t: 50, f: 12, X: -stepSize, Y: 0, Z: 0}
-		case "ArrowDown", "s", "S", "2":
-			queue <- Operation{op: ROTATE, t: 50, f: 12, X: stepSize, Y: 0, Z: 0}
-		case "7", "Home":
-			queue <- Operation{op: ROTATE, t: 50, f: 12, X: -stepSize, Y: -stepSize, Z: 0}
-		case "9", "PageUp":
-			queue <- Operation{op: ROTATE, t: 50, f: 12, X: -stepSize, Y: stepSize, Z: 0}
-		case "1", "End":
-			queue <- Operation{op: ROTATE, t: 50, f: 12, X: stepSize, Y: -stepSize, Z: 0}
-		case "3", "PageDown":
-			queue <- Operation{op: ROTATE, t: 50, f: 12, X: stepSize, Y: stepSize, Z: 0}
-		case "-":
-			queue <- Operation{op: ROTATE, t: 50, f: 12, X: 0, Y: 0, Z: -stepSize}
-		case "+":
-			queue <- Operation{op: ROTATE, t: 50, f: 12, X: 0, Y: 0, Z: stepSize}
+// Returns the given fraction (from 0 to 1) of a transformation matrix.  The matrix must only rotate, translate, and
+// scale evenly on all axes, which is true of every operation.  The rotation part is interpolated using quaternions, and
+// when there's scaling the interpolation is around the point the matrix leaves in place, so (for example) zooming in
+// on a point keeps that point still the whole way through
+func interpolateMatrix(m matrix, p float64) matrix {
+	if p >= 1 {
+		return m
+	}
+	if p <= 0 {
+		return identityMatrix
+	}
+
+	// Split the matrix into its scale and rotation parts
+	s := math.Sqrt(m[0]*m[0] + m[4]*m[4] + m[8]*m[8])
+	r := matrix{
+		m[0] / s, m[1] / s, m[2] / s, 0,
+		m[4] / s, m[5] / s, m[6] / s, 0,
+		m[8] / s, m[9] / s, m[10] / s, 0,
+		0, 0, 0, 1,
+	}
+	sPart := math.Pow(s, p)
+	part := scale(quatFromMatrix(r).pow(p).matrix(), sPart, sPart, sPart)
+
+	// With no scaling, the part of the translation along the rotation axis is spread evenly across the animation, and
+	// the rest of it comes from rotating around a line parallel to the axis.  So rotating around a pivot point keeps the
+	// pivot still the whole way through
+	if math.Abs(s-1) < 1e-9 {
+		u, angle := quatFromMatrix(r).axisAngle()
+		if angle < 1e-9 {
+			return translate(part, m[3]*p, m[7]*p, m[11]*p)
 		}
+		along := (m[3] * u.X) + (m[7] * u.Y) + (m[11] * u.Z)
+
+		// The line is through the point c, where (I - r) * c gives the rest of the translation.  Adding u * uᵀ makes the
+		// matrix invertible, and doesn't change the result, as c is at right angles to the axis
+		a := matrix{
+			1 - r[0] + (u.X * u.X), -r[1] + (u.X * u.Y), -r[2] + (u.X * u.Z), 0,
+			-r[4] + (u.Y * u.X), 1 - r[5] + (u.Y * u.Y), -r[6] + (u.Y * u.Z), 0,
+			-r[8] + (u.Z * u.X), -r[9] + (u.Z * u.Y), 1 - r[10] + (u.Z * u.Z), 0,
+			0, 0, 0, 1,
+		}
+		c := transform(invertMatrix(a), Point{X: m[3] - (along * u.X), Y: m[7] - (along * u.Y), Z: m[11] - (along * u.Z)})
+		part = aroundPoint(part, c)
+		return translate(part, along*u.X*p, along*u.Y*p, along*u.Z*p)
 	}
+
+	// Otherwise work out the fixed point f, where m * f = f, and transform around that
+	a := matrix{
+		1 - (s * r[0]), -s * r[1], -s * r[2], 0,
+		-s * r[4], 1 - (s * r[5]), -s * r[6], 0,
+		-s * r[8], -s * r[9], 1 - (s * r[10]), 0,
+		0, 0, 0, 1,
+	}
+	f := transform(invertMatrix(a), Point{X: m[3], Y: m[7], Z: m[11]})
+	part = matrixMult(part, translate(identityMatrix, -f.X, -f.Y, -f.Z))
+	return translate(part, f.X, f.Y, f.Z)
+}
+
+// Returns the inverse of a transformation matrix.  The bottom row is assumed to be 0, 0, 0, 1, as it is for all of the
+// transformations used here
+func invertMatrix(m matrix) matrix {
+	// Invert the upper 3x3 part, using its cofactors
+	c0 := (m[5] * m[10]) - (m[6] * m[9])
+	c1 := (m[6] * m[8]) - (m[4] * m[10])
+	c2 := (m[4] * m[9]) - (m[5] * m[8])
+	det := (m[0] * c0) + (m[1] * c1) + (m[2] * c2)
+	if det == 0 {
+		return identityMatrix
+	}
+	inv := matrix{
+		c0 / det, ((m[2] * m[9]) - (m[1] * m[10])) / det, ((m[1] * m[6]) - (m[2] * m[5])) / det, 0,
+		c1 / det, ((m[0] * m[10]) - (m[2] * m[8])) / det, ((m[2] * m[4]) - (m[0] * m[6])) / det, 0,
+		c2 / det, ((m[1] * m[8]) - (m[0] * m[9])) / det, ((m[0] * m[5]) - (m[1] * m[4])) / det, 0,
+		0, 0, 0, 1,
+	}
+
+	// The inverse translation is the original one, moved backwards through the inverted 3x3 part
+	t := transform(inv, Point{X: m[3], Y: m[7], Z: m[11]})
+	inv[3], inv[7], inv[11] = -t.X, -t.Y, -t.Z
+	return inv
+}
+
+// Returns true if the object is a graph of the equation or one of its derivatives
+func isGraph(o Object) bool {
+	return o.Eq != ""
 }
 
 // Pretty formatting of maths strings.  Changes (say) x^3 to x³
@@ -522,6 +489,16 @@ func mathFormat(s string) string {
 	return strings.Replace(t, "*", "", -1)
 }
 
+// Returns true if two matrices are (near enough to) equal
+func matrixEqual(a matrix, b matrix) bool {
+	for j := range a {
+		if math.Abs(a[j]-b[j]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
 // Multiplies one matrix by another
 func matrixMult(opMatrix matrix, m matrix) (resultMatrix matrix) {
 	top0 := m[0]
@@ -565,291 +542,76 @@ func matrixMult(opMatrix matrix, m matrix) (resultMatrix matrix) {
 	return resultMatrix
 }
 
-// Simple mouse handler watching for people moving the mouse over the source code link
-func moveHandler(args []js.Value) {
-	event := args[0]
-	clientX := event.Get("clientX").Float()
-	clientY := event.Get("clientY").Float()
-	if debug {
-		fmt.Printf("ClientX: %v  clientY: %v\n", clientX, clientY)
-	}
-
-	// If the mouse is over the source code link, let the frame renderer know to draw the url in bold
-	if clientX > graphWidth && clientY > (height-40) {
-		highLightSource = true
-	} else {
-		highLightSource = false
-	}
-}
-
-// Animates the transformation operations
-func processOperations(queue <-chan Operation) {
-	for i := range queue {
-		renderActive.Store(true)         // Mark rendering as now in progress
-		parts := i.f                     // Number of parts to break each transformation into
-		transformMatrix = identityMatrix // Reset the transform matrix
-		switch i.op {
-		case ROTATE: // Rotate the objects in world space
-			// Divide the desired angle into a small number of parts
-			if i.X != 0 {
-				transformMatrix = rotateAroundX(transformMatrix, i.X/float64(parts))
-			}
-			if i.Y != 0 {
-				transformMatrix = rotateAroundY(transformMatrix, i.Y/float64(parts))
-			}
-			if i.Z != 0 {
-				transformMatrix = rotateAroundZ(transformMatrix, i.Z/float64(parts))
-			}
-			opText = fmt.Sprintf("Rotation. X: %0.2f Y: %0.2f Z: %0.2f", i.X, i.Y, i.Z)
-
-		case SCALE:
-			// Scale the objects in world space
-			var xPart, yPart, zPart float64
-			if i.X != 1 {
-				xPart = ((i.X - 1) / float64(parts)) + 1
-			}
-			if i.Y != 1 {
-				yPart = ((i.Y - 1) / float64(parts)) + 1
-			}
-			if i.Z != 1 {
-				zPart = ((i.Z - 1) / float64(parts)) + 1
-			}
-			transformMatrix = scale(transformMatrix, xPart, yPart, zPart)
-			opText = fmt.Sprintf("Scale. X: %0.2f Y: %0.2f Z: %0.2f", i.X, i.Y, i.Z)
-
-		case TRANSLATE:
-			// Translate (move) the objects in world space
-			transformMatrix = translate(transformMatrix, i.X/float64(parts), i.Y/float64(parts), i.Z/float64(parts))
-			opText = fmt.Sprintf("Translate (move). X: %0.2f Y: %0.2f Z: %0.2f", i.X, i.Y, i.Z)
+// Returns the transformation matrix for an operation
+func operationMatrix(i Operation) matrix {
+	m := identityMatrix
+	switch i.Op {
+	case ROTATE: // Rotate the objects in world space
+		if i.X != 0 {
+			m = rotateAroundX(m, i.X)
 		}
-
-		// Apply each transformation, one small part at a time (this gives the animation effect)
-		timeSlice := time.Millisecond * time.Duration(i.t/parts)
-		for t := 0; t < int(parts); t++ {
-			time.Sleep(timeSlice)
-			for j, o := range worldSpace {
-				var newPoints []Point
-
-				// Transform each point of in the object
-				for _, j := range o.P {
-					newPoints = append(newPoints, transform(transformMatrix, j))
-				}
-				o.P = newPoints
-
-				// Update the object in world space
-				worldSpace[j] = o
-			}
+		if i.Y != 0 {
+			m = rotateAroundY(m, i.Y)
 		}
-		renderActive.Store(false)
-		opText = "Complete."
-	}
-}
-
-// Renders one frame of the animation
-func renderFrame(args []js.Value) {
-	// Handle window resizing
-	curBodyW := doc.Get("body").Get("clientWidth").Float()
-	curBodyH := doc.Get("body").Get("clientHeight").Float()
-	if curBodyW != width || curBodyH != height {
-		width, height = curBodyW, curBodyH
-		canvasEl.Set("width", width)
-		canvasEl.Set("height", height)
-	}
-
-	// Setup useful variables
-	border := float64(2)
-	gap := float64(3)
-	left := border + gap
-	top := border + gap
-	graphWidth = width * 0.75
-	graphHeight = height - 1
-	centerX := graphWidth / 2
-	centerY := graphHeight / 2
-
-	// Clear the background
-	ctx.Set("fillStyle", "white")
-	ctx.Call("fillRect", 0, 0, width, height)
-
-	// Draw grid lines
-	step := math.Min(width, height) / 30
-	ctx.Set("strokeStyle", "rgb(220, 220, 220)")
-	ctx.Call("setLineDash", []interface{}{1, 3})
-	for i := left; i < graphWidth-step; i += step {
-		// Vertical dashed lines
-		ctx.Call("beginPath")
-		ctx.Call("moveTo", i+step, top)
-		ctx.Call("lineTo", i+step, graphHeight)
-		ctx.Call("stroke")
-	}
-	for i := top; i < graphHeight-step; i += step {
-		// Horizontal dashed lines
-		ctx.Call("beginPath")
-		ctx.Call("moveTo", left, i+step)
-		ctx.Call("lineTo", graphWidth-border, i+step)
-		ctx.Call("stroke")
-	}
-
-	// Draw the axes
-	var pointX, pointY float64
-	ctx.Set("strokeStyle", "black")
-	ctx.Set("lineWidth", "1")
-	ctx.Call("setLineDash", []interface{}{})
-	for _, o := range worldSpace {
-		// Draw the surfaces
-		ctx.Set("fillStyle", o.C)
-		for _, l := range o.S {
-			for m, n := range l {
-				pointX = o.P[n].X
-				pointY = o.P[n].Y
-				if m == 0 {
-					ctx.Call("beginPath")
-					ctx.Call("moveTo", centerX+(pointX*step), centerY+((pointY*step)*-1))
-				} else {
-					ctx.Call("lineTo", centerX+(pointX*step), centerY+((pointY*step)*-1))
-				}
-			}
-			ctx.Call("closePath")
-			ctx.Call("fill")
+		if i.Z != 0 {
+			m = rotateAroundZ(m, i.Z)
 		}
 
-		// Draw the edges
-		var point1X, point1Y, point2X, point2Y float64
-		for _, l := range o.E {
-			point1X = o.P[l[0]].X
-			point1Y = o.P[l[0]].Y
-			point2X = o.P[l[1]].X
-			point2Y = o.P[l[1]].Y
-			ctx.Call("beginPath")
-			ctx.Call("moveTo", centerX+(point1X*step), centerY+((point1Y*step)*-1))
-			ctx.Call("lineTo", centerX+(point2X*step), centerY+((point2Y*step)*-1))
-			ctx.Call("stroke")
-		}
+	case SCALE:
+		// Scale the objects in world space
+		m = scale(m, i.X, i.Y, i.Z)
 
-		// Draw any point labels
-		ctx.Set("fillStyle", "black")
-		ctx.Set("font", "bold 16px serif")
-		var px, py float64
-		for _, l := range o.P {
-			if l.Label != "" {
-				ctx.Set("textAlign", l.LabelAlign)
-				px = centerX + (l.X * step)
-				py = centerY + ((l.Y * step) * -1)
-				ctx.Call("fillText", l.Label, px, py)
-			}
-		}
-	}
+	case TRANSLATE:
+		// Translate (move) the objects in world space
+		m = translate(m, i.X, i.Y, i.Z)
 
-	// Draw the graph and derivatives
-	ctx.Set("lineWidth", "2")
-	ctx.Call("setLineDash", []interface{}{})
-	var px, py float64
-	numWld := len(worldSpace)
-	for i := 0; i < numWld; i++ {
-		o := worldSpace[i]
-		if o.Name != "axes" {
-			// Draw lines between the points
-			ctx.Set("strokeStyle", o.C)
-			ctx.Call("beginPath")
-			for k, l := range o.P {
-				px = centerX + (l.X * step)
-				py = centerY + ((l.Y * step) * -1)
-				if k == 0 {
-					ctx.Call("moveTo", px, py)
-				} else {
-					ctx.Call("lineTo", px, py)
-				}
-			}
-			ctx.Call("stroke")
-
-			// Draw dots for the points
-			ctx.Set("fillStyle", "black")
-			for _, l := range o.P {
-				px = centerX + (l.X * step)
-				py = centerY + ((l.Y * step) * -1)
-				ctx.Call("beginPath")
-				ctx.Call("ellipse", px, py, 1, 1, 0, 0, 2*math.Pi)
-				ctx.Call("fill")
-				ctx.Call("stroke")
-			}
-		}
+	case ZOOM:
+		// Scale, then translate the objects in world space
+		m = scale(m, i.S, i.S, i.S)
+		m = translate(m, i.X, i.Y, i.Z)
 	}
+	return m
+}
 
-	// Clear the information area (right side)
-	ctx.Set("fillStyle", "white")
-	ctx.Call("fillRect", graphWidth+1, 0, width, height)
-
-	// Draw the text describing the current operation
-	textY := top + 20
-	ctx.Set("fillStyle", "black")
-	ctx.Set("font", "bold 14px serif")
-	ctx.Set("textAlign", "left")
-	ctx.Call("fillText", "Operation:", graphWidth+20, textY)
-	textY += 20
-	ctx.Set("font", "14px sans-serif")
-	ctx.Call("fillText", opText, graphWidth+20, textY)
-	textY += 30
-
-	// Add the help text about control keys and mouse zoom
-	ctx.Set("fillStyle", "blue")
-	ctx.Set("font", "14px sans-serif")
-	ctx.Call("fillText", "Use wasd/numpad keys to rotate,", graphWidth+20, textY)
-	textY += 20
-	ctx.Call("fillText", "mouse wheel to zoom.", graphWidth+20, textY)
-	textY += 30
-
-	// Add the graph and derivatives information
-	ctx.Set("fillStyle", "black")
-	for i := 0; i < numWld; i++ {
-		o := worldSpace[i]
-		if o.Name != "axes" {
-			ctx.Set("font", "bold 18px serif")
-			ctx.Call("fillText", o.Name, graphWidth+20, textY)
-			textY += 20
-			ctx.Set("font", "16px sans-serif")
-			ctx.Call("fillText", o.Eq, graphWidth+40, textY)
-			textY += 30
-		}
+// Returns the view matrix after applying an operation to the given view matrix.  Rotation and scaling are done around
+// the pivot point.  The scene lock must be held by the caller
+func operationTarget(i Operation, m matrix) matrix {
+	switch i.Op {
+	case VIEW:
+		return i.M
+	case ROTATE, SCALE:
+		return matrixMult(aroundPoint(operationMatrix(i), pivotView(m)), m)
 	}
+	return matrixMult(operationMatrix(i), m)
+}
 
-	// Clear the source code link area
-	ctx.Set("fillStyle", "white")
-	ctx.Call("fillRect", graphWidth+1, graphHeight-55, width, height)
+// Returns the text describing an operation, for display in the information area
+func operationText(i Operation) string {
+	switch i.Op {
+	case ROTATE:
+		return fmt.Sprintf("Rotation. X: %0.2f Y: %0.2f Z: %0.2f", i.X, i.Y, i.Z)
+	case SCALE:
+		return fmt.Sprintf("Scale. X: %0.2f Y: %0.2f Z: %0.2f", i.X, i.Y, i.Z)
+	case TRANSLATE:
+		return fmt.Sprintf("Translate (move). X: %0.2f Y: %0.2f Z: %0.2f", i.X, i.Y, i.Z)
+	case ZOOM:
+		return fmt.Sprintf("Zoom. Scale: %0.2f X: %0.2f Y: %0.2f Z: %0.2f", i.S, i.X, i.Y, i.Z)
+	case VIEW:
+		return "Change view."
+	}
+	return ""
+}
 
-	// Add the URL to the source code
-	ctx.Set("fillStyle", "black")
-	ctx.Set("font", "bold 14px serif")
-	ctx.Call("fillText", "Source code:", graphWidth+20, graphHeight-35)
-	ctx.Set("fillStyle", "blue")
-	if highLightSource == true {
-		ctx.Set("font", "bold 12px sans-serif")
-	} else {
-		ctx.Set("font", "12px sans-serif")
-	}
-	ctx.Call("fillText", sourceURL, graphWidth+20, graphHeight-15)
-
-	// Draw a border around the graph area
-	ctx.Call("setLineDash", []interface{}{})
-	ctx.Set("lineWidth", "2")
-	ctx.Set("strokeStyle", "white")
-	ctx.Call("beginPath")
-	ctx.Call("moveTo", 0, 0)
-	ctx.Call("lineTo", width, 0)
-	ctx.Call("lineTo", width, height)
-	ctx.Call("lineTo", 0, height)
-	ctx.Call("closePath")
-	ctx.Call("stroke")
-	ctx.Set("lineWidth", "2")
-	ctx.Set("strokeStyle", "black")
-	ctx.Call("beginPath")
-	ctx.Call("moveTo", border, border)
-	ctx.Call("lineTo", graphWidth, border)
-	ctx.Call("lineTo", graphWidth, graphHeight)
-	ctx.Call("lineTo", border, graphHeight)
-	ctx.Call("closePath")
-	ctx.Call("stroke")
-
-	// Schedule the next frame render call
-	js.Global().Call("requestAnimationFrame", rCall)
+// Returns a TRANSLATE operation which pans the world space by the given number of pixels.  Translations are applied
+// after any rotation, so the pan follows the screen regardless of how the graph has been rotated
+func panOp(dx float64, dy float64, t int32) Operation {
+	step := pixelsPerUnit()
+	return Operation{Op: TRANSLATE, T: t, E: EASEINOUT, X: dx / step, Y: (dy / step) * -1, Z: 0}
+}
+
+// Returns the number of pixels per world space unit
+func pixelsPerUnit() float64 {
+	return pixelsPerUnitAt(width, height)
 }
 
 // Rotates a transformation matrix around the X axis by the given degrees
@@ -899,6 +661,12 @@ func scale(m matrix, x float64, y float64, z float64) matrix {
 	return matrixMult(scaleMatrix, m)
 }
 
+// Returns the world space X and Y co-ordinates under the given point of the canvas
+func screenToWorld(x float64, y float64) (float64, float64) {
+	step := pixelsPerUnit()
+	return (x - graphWidth/2) / step, ((y - graphHeight/2) / step) * -1
+}
+
 // Returns the name/label prefix for a derivative string
 func strDeriv(i int) string {
 	switch i {
@@ -940,6 +708,20 @@ func transform(m matrix, p Point) (t Point) {
 	return
 }
 
+// Returns copies of the objects, with their points transformed by the transformation matrix
+func transformObjects(m matrix, objs []Object) []Object {
+	t := make([]Object, len(objs))
+	for j, o := range objs {
+		pts := make([]Point, len(o.P))
+		for k, p := range o.P {
+			pts[k] = transform(m, p)
+		}
+		o.P = pts
+		t[j] = o
+	}
+	return t
+}
+
 // Translates (moves) a transformation matrix by the given X, Y and Z values
 func translate(m matrix, translateX float64, translateY float64, translateZ float64) matrix {
 	translateMatrix := matrix{
@@ -951,18 +733,63 @@ func translate(m matrix, translateX float64, translateY float64, translateZ floa
 	return matrixMult(translateMatrix, m)
 }
 
-// Simple mouse handler watching for mouse wheel events
-// Reference info can be found here: https://developer.mozilla.org/en-US/docs/Web/Events/wheel
-func wheelHandler(args []js.Value) {
-	event := args[0]
-	wheelDelta := event.Get("deltaY").Float()
-	scaleSize := 1 + (wheelDelta / 5)
-	if debug {
-		fmt.Printf("Wheel delta: %v, scaleSize: %v\n", wheelDelta, scaleSize)
+// Regenerates the axes object in world space, to suit the current graph domain, zoom level, and the part of the graph
+// in view
+func updateAxes() {
+	ax := generateAxes(graphDomain, zoomLevel(), axisCentres(viewMatrix))
+	for j, o := range worldSpace {
+		if o.Name == "axes" {
+			worldSpace[j] = ax
+		}
+	}
+}
+
+// Regenerates the grid objects in world space, to suit the selected planes, the current graph domain, zoom level, and
+// the part of the graph in view
+func updateGrid() {
+	// The grid goes at the start of the world space, so it's drawn underneath everything else
+	objs := generateGrid(gridPlanes, graphDomain, zoomLevel(), axisCentres(viewMatrix))
+	for _, o := range worldSpace {
+		if o.Name != "grid" {
+			objs = append(objs, o)
+		}
 	}
+	worldSpace = objs
+}
 
-	// Don't add operations if one is already in progress
-	if !renderActive.Load() {
-		queue <- Operation{op: SCALE, t: 50, f: 12, X: scaleSize, Y: scaleSize, Z: scaleSize}
+// Returns an error if an equation has characters which aren't allowed in one.  The given parameter names are allowed
+// too, as whole words
+func validEquation(eq string, params ...string) error {
+	if bad := badEquationChars(eq, params...); bad != "" {
+		return fmt.Errorf("bad characters in equation: %s", bad)
 	}
+	return nil
+}
+
+// Returns the current zoom level (scale factor) of the world space
+func zoomLevel() float64 {
+	return math.Sqrt(viewMatrix[0]*viewMatrix[0] + viewMatrix[4]*viewMatrix[4] + viewMatrix[8]*viewMatrix[8])
+}
+
+// Returns a ZOOM operation which scales the world space by s, then translates it by the given X and Y amounts
+func zoomOp(s float64, x float64, y float64) Operation {
+	return Operation{Op: ZOOM, T: 250, E: EASEINOUT, X: x, Y: y, Z: 0, S: s}
+}
+
+// Zooms in so the area inside the zoom box fills the graph area.  Tiny boxes are ignored, as they're most likely
+// accidental clicks
+func zoomToBox() {
+	sceneLock.Lock()
+	boxX, boxY := zoomBoxX, zoomBoxY
+	sceneLock.Unlock()
+	boxW := math.Abs(boxX[1] - boxX[0])
+	boxH := math.Abs(boxY[1] - boxY[0])
+	if boxW < 5 || boxH < 5 {
+		return
+	}
+
+	// Scale the box up to fill the graph area, moving its center to the center of the graph area
+	s := math.Min(graphWidth/boxW, graphHeight/boxH)
+	x, y := screenToWorld((boxX[0]+boxX[1])/2, (boxY[0]+boxY[1])/2)
+	submitOperation(zoomOp(s, -x*s, -y*s))
 }
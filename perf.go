@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Timings and counts for the frames drawn recently, shown by the performance overlay and readable from javascript
+type perfStats struct {
+	FPS        float64 // Frames drawn over the last second
+	FrameTime  float64 // Milliseconds between the last two frames drawn
+	RenderTime float64 // Milliseconds spent drawing the last frame
+	EvalTime   float64 // Milliseconds spent evaluating the expressions for the last equation graphed
+	Points     int     // Number of points in the last frame drawn
+	JSCalls    int     // Number of calls into javascript made drawing the last frame
+}
+
+var (
+	// Protects the performance statistics and overlay flag, as the evaluation time is recorded from the goroutine
+	// doing the graphing
+	perfLock sync.Mutex
+	perf     perfStats
+	showPerf bool
+
+	// The frame times of the frames drawn over the last second
+	perfFrames []float64
+
+	// Calls into javascript made since the start of the current frame.  Only the frame renderer touches this
+	jsCalls int
+)
+
+// Draws the performance overlay in the top left corner of the graph area
+func drawPerf(r Renderer, p perfStats) {
+	lines := perfLines(p)
+	r.SetFillStyle("rgba(255, 255, 255, 0.85)")
+	r.FillRect(10, 10, 230, float64(len(lines))*16+10)
+	r.SetStrokeStyle("black")
+	r.SetLineWidth(1)
+	r.SetLineDash(nil)
+	r.StrokeRect(10, 10, 230, float64(len(lines))*16+10)
+	r.SetFillStyle("black")
+	r.SetFont("12px monospace")
+	r.SetTextAlign("left")
+	for i, l := range lines {
+		r.FillText(l, 18, 28+float64(i)*16)
+	}
+}
+
+// Returns the lines of text shown by the performance overlay
+func perfLines(p perfStats) []string {
+	return []string{
+		fmt.Sprintf("FPS:          %.0f", p.FPS),
+		fmt.Sprintf("Frame time:   %.1fms", p.FrameTime),
+		fmt.Sprintf("Render time:  %.1fms", p.RenderTime),
+		fmt.Sprintf("Eval time:    %.0fms", p.EvalTime),
+		fmt.Sprintf("Points:       %d", p.Points),
+		fmt.Sprintf("JS calls:     %d", p.JSCalls),
+	}
+}
+
+// Returns true if the performance overlay is being shown
+func perfShown() bool {
+	perfLock.Lock()
+	defer perfLock.Unlock()
+	return showPerf
+}
+
+// Returns the performance statistics
+func perfSnapshot() perfStats {
+	perfLock.Lock()
+	defer perfLock.Unlock()
+	return perf
+}
+
+// Records the time taken to evaluate the expressions for an equation being graphed, in milliseconds
+func recordEval(ms float64) {
+	perfLock.Lock()
+	defer perfLock.Unlock()
+	perf.EvalTime = ms
+}
+
+// Records a frame being drawn at the given frame time, along with the time taken to draw it, the number of points
+// in it, and the number of calls into javascript made drawing it.  Frames which didn't need drawing are left out, but
+// still move the frame clock on so the frame rate drops when nothing's being drawn
+func recordFrame(now float64, drawn bool, renderTime float64, points int, calls int) {
+	perfLock.Lock()
+	defer perfLock.Unlock()
+	if drawn {
+		if n := len(perfFrames); n > 0 {
+			perf.FrameTime = now - perfFrames[n-1]
+		}
+		perfFrames = append(perfFrames, now)
+		perf.RenderTime = renderTime
+		perf.Points = points
+		perf.JSCalls = calls
+	}
+
+	// Drop the frames from more than a second ago
+	i := 0
+	for i < len(perfFrames) && now-perfFrames[i] >= 1000 {
+		i++
+	}
+	perfFrames = perfFrames[i:]
+	perf.FPS = float64(len(perfFrames))
+}
+
+// Returns the number of points in a scene snapshot
+func scenePoints(sc *scene) (n int) {
+	for _, o := range sc.objects {
+		n += len(o.P)
+	}
+	return
+}
+
+// Shows or hides the performance overlay
+func togglePerf() {
+	perfLock.Lock()
+	showPerf = !showPerf
+	perfLock.Unlock()
+	markDirty()
+}
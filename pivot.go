@@ -0,0 +1,96 @@
+package main
+
+import "math"
+
+const (
+	// Clicking within this many pixels of a graph point sets the pivot to that point
+	pivotPickDistance = 10
+)
+
+var (
+	// The point (in model space) rotations and scaling are made around.  These are protected by sceneLock
+	pivotPoint Point
+	pivotSet   bool // True if the user chose the pivot point, rather than it being the default
+)
+
+// Returns a transformation matrix which applies the given one around a point, rather than around the origin.  This is
+// done by moving the point to the origin, transforming, then moving it back again
+func aroundPoint(m matrix, p Point) matrix {
+	m = matrixMult(m, translate(identityMatrix, -p.X, -p.Y, -p.Z))
+	return translate(m, p.X, p.Y, p.Z)
+}
+
+// Puts the pivot point back to the default, the centre of whatever part of the graph is visible
+func clearPivot() {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	pivotSet = false
+	updatePivot()
+	opText = "Pivot reset."
+	publishScene()
+}
+
+// Returns the pivot point in view space, for the given view matrix.  The scene lock must be held by the caller
+func pivotView(m matrix) Point {
+	return transform(m, pivotPoint)
+}
+
+// Sets the pivot point to the point under the given canvas co-ordinates.  If there's a graph point close by, the pivot
+// goes exactly on that.  Otherwise it goes on the plane through the screen centre, facing the viewer
+func setPivot(x float64, y float64) {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	step := pixelsPerUnit()
+	wx, wy := screenToWorld(x, y)
+	p := Point{X: wx, Y: wy}
+	best := float64(pivotPickDistance)
+	for _, o := range worldSpace {
+		if !isGraph(o) {
+			continue
+		}
+		for _, q := range o.P {
+			v := transform(viewMatrix, q)
+			if d := math.Hypot(v.X-wx, v.Y-wy) * step; d < best {
+				best = d
+				p = v
+			}
+		}
+	}
+	pivotPoint = transform(invertMatrix(viewMatrix), p)
+	pivotSet = true
+	opText = "Pivot set."
+	publishScene()
+}
+
+// Moves the default pivot point to the centre of the bounding box of the visible graph points.  A pivot chosen by the
+// user is left alone.  This is only done when the graph is zoomed or moved, rather than after every rotation, so the
+// pivot doesn't wander as points rotate in and out of view.  The scene lock must be held by the caller
+func updatePivot() {
+	if pivotSet {
+		return
+	}
+	step := pixelsPerUnit()
+	maxX, maxY := graphWidth/2/step, graphHeight/2/step
+	minP := Point{X: math.Inf(1), Y: math.Inf(1), Z: math.Inf(1)}
+	maxP := Point{X: math.Inf(-1), Y: math.Inf(-1), Z: math.Inf(-1)}
+	for _, o := range worldSpace {
+		if !isGraph(o) {
+			continue
+		}
+		for _, q := range o.P {
+			v := transform(viewMatrix, q)
+			if math.Abs(v.X) > maxX || math.Abs(v.Y) > maxY {
+				continue
+			}
+			minP = Point{X: math.Min(minP.X, v.X), Y: math.Min(minP.Y, v.Y), Z: math.Min(minP.Z, v.Z)}
+			maxP = Point{X: math.Max(maxP.X, v.X), Y: math.Max(maxP.Y, v.Y), Z: math.Max(maxP.Z, v.Z)}
+		}
+	}
+
+	// With nothing visible, pivot around the centre of the screen
+	var c Point
+	if minP.X <= maxP.X {
+		c = Point{X: (minP.X + maxP.X) / 2, Y: (minP.Y + maxP.Y) / 2, Z: (minP.Z + maxP.Z) / 2}
+	}
+	pivotPoint = transform(invertMatrix(viewMatrix), c)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// Sets up the test graph for a pivot test, with the given view and a 900x600 page, which puts 20 pixels to each world
+// space unit.  Returns a function putting the page size back
+func pivotScene(view matrix) func() {
+	sc := testScene(TOPVIEW)
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	savedW, savedH, savedGW, savedGH := width, height, graphWidth, graphHeight
+	width, height = 900, 600
+	graphWidth, graphHeight = width*0.75, height-1
+	worldSpace = sc.objects
+	viewMatrix = view
+	pivotPoint, pivotSet = Point{}, false
+	return func() {
+		sceneLock.Lock()
+		defer sceneLock.Unlock()
+		width, height, graphWidth, graphHeight = savedW, savedH, savedGW, savedGH
+		pivotPoint, pivotSet = Point{}, false
+	}
+}
+
+// Returns true if two points are the same, to within rounding error
+func pointNear(a Point, b Point) bool {
+	return math.Abs(a.X-b.X) < 1e-9 && math.Abs(a.Y-b.Y) < 1e-9 && math.Abs(a.Z-b.Z) < 1e-9
+}
+
+func TestSetPivot(t *testing.T) {
+	moved := translate(identityMatrix, 2, -1, 0)
+	tests := []struct {
+		name string
+		view matrix
+		x, y float64 // Canvas co-ordinates clicked on
+		want Point   // The pivot point, in model space
+	}{
+		// Clicking a few pixels from a graph point puts the pivot exactly on it.  The graph area is centred on
+		// (337.5, 299.5), and y = x³/3 ends at (3, 9)
+		{"near a graph point", identityMatrix, 337.5 + 3*20 + 4, 299.5 - 9*20 - 3, Point{X: 3, Y: 9}},
+		{"near a moved graph point", moved, 337.5 + 5*20 + 4, 299.5 - 8*20 - 3, Point{X: 3, Y: 9}},
+
+		// Anywhere else, it goes where the click was, on the plane through the screen centre
+		{"away from the graph", identityMatrix, 337.5 - 40, 299.5 - 100, Point{X: -2, Y: 5}},
+		{"away from the moved graph", moved, 337.5 - 40, 299.5 - 100, Point{X: -4, Y: 6}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			defer pivotScene(tc.view)()
+			setPivot(tc.x, tc.y)
+			sceneLock.Lock()
+			defer sceneLock.Unlock()
+			if !pivotSet || !pointNear(pivotPoint, tc.want) {
+				t.Errorf("pivot is %+v (set %v), want %+v", pivotPoint, pivotSet, tc.want)
+			}
+		})
+	}
+}
+
+func TestUpdatePivot(t *testing.T) {
+	tests := []struct {
+		name string
+		view matrix
+		want Point // The pivot point, in model space
+	}{
+		// The whole graph is in view, from (-3, -9) to (3, 9)
+		{"whole graph", identityMatrix, Point{}},
+
+		// Zoomed in on the top of the graph, only the points from x = 2.1 up are in view
+		{"zoomed in", translate(scale(identityMatrix, 4, 4, 4), -10.8, -26, 0),
+			Point{X: 2.55, Y: (2.1*2.1*2.1/3 + 9) / 2}},
+
+		// With nothing in view, the pivot goes on the centre of the screen
+		{"nothing in view", translate(identityMatrix, 100, 0, 0), Point{X: -100}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			defer pivotScene(tc.view)()
+			sceneLock.Lock()
+			defer sceneLock.Unlock()
+			updatePivot()
+			if !pointNear(pivotPoint, tc.want) {
+				t.Errorf("pivot is %+v, want %+v", pivotPoint, tc.want)
+			}
+
+			// A pivot the user chose is left alone
+			pivotPoint, pivotSet = Point{X: 7}, true
+			updatePivot()
+			if pivotPoint != (Point{X: 7}) {
+				t.Errorf("the user's pivot was moved to %+v", pivotPoint)
+			}
+		})
+	}
+}
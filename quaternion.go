@@ -0,0 +1,120 @@
+package main
+
+import "math"
+
+// A rotation, stored as a unit quaternion.  Accumulating rotations this way avoids the gimbal lock problems of
+// accumulating Euler angles
+type quaternion struct {
+	W float64
+	X float64
+	Y float64
+	Z float64
+}
+
+// A direction, or a point, in 3D space
+type vector struct {
+	X float64
+	Y float64
+	Z float64
+}
+
+// Returns the axis and angle (in radians) of the rotation
+func (q quaternion) axisAngle() (axis vector, angle float64) {
+	w := math.Max(-1, math.Min(1, q.W))
+	angle = 2 * math.Acos(w)
+	s := math.Sqrt(1 - w*w)
+	if s < 1e-9 {
+		return vector{X: 1}, 0
+	}
+	axis = vector{X: q.X / s, Y: q.Y / s, Z: q.Z / s}
+	if angle > math.Pi {
+		// Take the short way around
+		angle = 2*math.Pi - angle
+		axis = vector{X: -axis.X, Y: -axis.Y, Z: -axis.Z}
+	}
+	return
+}
+
+// Returns the conjugate of the quaternion, which for a unit quaternion is the opposite rotation
+func (q quaternion) conj() quaternion {
+	return quaternion{W: q.W, X: -q.X, Y: -q.Y, Z: -q.Z}
+}
+
+// Returns the 4x4 rotation matrix equivalent to the quaternion
+func (q quaternion) matrix() matrix {
+	w, x, y, z := q.W, q.X, q.Y, q.Z
+	return matrix{
+		1 - 2*(y*y+z*z), 2 * (x*y - w*z), 2 * (x*z + w*y), 0,
+		2 * (x*y + w*z), 1 - 2*(x*x+z*z), 2 * (y*z - w*x), 0,
+		2 * (x*z - w*y), 2 * (y*z + w*x), 1 - 2*(x*x+y*y), 0,
+		0, 0, 0, 1,
+	}
+}
+
+// Multiplies one quaternion by another.  The resulting rotation is r followed by q
+func (q quaternion) mult(r quaternion) quaternion {
+	return quaternion{
+		W: q.W*r.W - q.X*r.X - q.Y*r.Y - q.Z*r.Z,
+		X: q.W*r.X + q.X*r.W + q.Y*r.Z - q.Z*r.Y,
+		Y: q.W*r.Y - q.X*r.Z + q.Y*r.W + q.Z*r.X,
+		Z: q.W*r.Z + q.X*r.Y - q.Y*r.X + q.Z*r.W,
+	}
+}
+
+// Returns the quaternion scaled back to unit length, to stop rounding errors accumulating
+func (q quaternion) normalise() quaternion {
+	l := math.Sqrt(q.W*q.W + q.X*q.X + q.Y*q.Y + q.Z*q.Z)
+	if l == 0 {
+		return quaternion{W: 1}
+	}
+	return quaternion{W: q.W / l, X: q.X / l, Y: q.Y / l, Z: q.Z / l}
+}
+
+// Returns the given fraction of the rotation, taking the short way around.  A fraction of 0 gives no rotation at all,
+// and a fraction of 1 gives the full rotation
+func (q quaternion) pow(p float64) quaternion {
+	axis, angle := q.axisAngle()
+	return quatFromAxisAngle(axis, angle*p)
+}
+
+// Returns the rotation which turns unit vector a into unit vector b
+func quatBetween(a vector, b vector) quaternion {
+	q := quaternion{
+		W: 1 + a.X*b.X + a.Y*b.Y + a.Z*b.Z,
+		X: a.Y*b.Z - a.Z*b.Y,
+		Y: a.Z*b.X - a.X*b.Z,
+		Z: a.X*b.Y - a.Y*b.X,
+	}
+	return q.normalise()
+}
+
+// Returns the rotation of the given number of radians around an axis
+func quatFromAxisAngle(axis vector, rad float64) quaternion {
+	l := math.Sqrt(axis.X*axis.X + axis.Y*axis.Y + axis.Z*axis.Z)
+	if l == 0 {
+		return quaternion{W: 1}
+	}
+	s := math.Sin(rad/2) / l
+	return quaternion{W: math.Cos(rad / 2), X: axis.X * s, Y: axis.Y * s, Z: axis.Z * s}
+}
+
+// Returns the rotation held in the upper 3x3 of a (pure rotation) transformation matrix
+func quatFromMatrix(m matrix) quaternion {
+	var q quaternion
+	tr := m[0] + m[5] + m[10]
+	switch {
+	case tr > 0:
+		s := math.Sqrt(tr+1) * 2
+		q = quaternion{W: s / 4, X: (m[9] - m[6]) / s, Y: (m[2] - m[8]) / s, Z: (m[4] - m[1]) / s}
+	case m[0] > m[5] && m[0] > m[10]:
+		s := math.Sqrt(1+m[0]-m[5]-m[10]) * 2
+		q = quaternion{W: (m[9] - m[6]) / s, X: s / 4, Y: (m[1] + m[4]) / s, Z: (m[2] + m[8]) / s}
+	case m[5] > m[10]:
+		s := math.Sqrt(1+m[5]-m[0]-m[10]) * 2
+		q = quaternion{W: (m[2] - m[8]) / s, X: (m[1] + m[4]) / s, Y: s / 4, Z: (m[6] + m[9]) / s}
+	default:
+		s := math.Sqrt(1+m[10]-m[0]-m[5]) * 2
+		q = quaternion{W: (m[4] - m[1]) / s, X: (m[2] + m[8]) / s, Y: (m[6] + m[9]) / s, Z: s / 4}
+	}
+	return q.normalise()
+}
@@ -0,0 +1,537 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A point on a raster path, in pixels
+type rasterPoint struct {
+	X, Y float64
+}
+
+// A sub-path of a raster path
+type rasterSubPath struct {
+	points []rasterPoint
+	closed bool
+}
+
+// A renderer which draws into an image in memory, with no browser needed.  Everything is drawn without anti-aliasing,
+// so the same scene always gives exactly the same pixels, which makes the images suitable for comparing against known
+// good ones.  Text is drawn with a small built in bitmap font, scaled up to roughly the requested size
+type rasterRenderer struct {
+	img       *image.RGBA
+	fill      color.RGBA
+	stroke    color.RGBA
+	lineWidth float64
+	dash      []float64
+	fontSize  float64
+	bold      bool
+	align     string
+	path      []rasterSubPath
+}
+
+const (
+	// The number of straight line segments used to draw an ellipse
+	ellipseSegments = 24
+
+	// The bitmap font glyphs are this many pixels wide and high, before scaling.  The glyphs are spaced a pixel apart
+	glyphWidth  = 5
+	glyphHeight = 7
+)
+
+var (
+	// The CSS colour names used in the scenes, and a few other common ones
+	colourNames = map[string]color.RGBA{
+		"black":          {0, 0, 0, 255},
+		"white":          {255, 255, 255, 255},
+		"red":            {255, 0, 0, 255},
+		"green":          {0, 128, 0, 255},
+		"blue":           {0, 0, 255, 255},
+		"yellow":         {255, 255, 0, 255},
+		"magenta":        {255, 0, 255, 255},
+		"cyan":           {0, 255, 255, 255},
+		"grey":           {128, 128, 128, 255},
+		"gray":           {128, 128, 128, 255},
+		"lightgrey":      {211, 211, 211, 255},
+		"lightgray":      {211, 211, 211, 255},
+		"darkgrey":       {169, 169, 169, 255},
+		"darkgray":       {169, 169, 169, 255},
+		"orange":         {255, 165, 0, 255},
+		"purple":         {128, 0, 128, 255},
+		"chocolate":      {210, 105, 30, 255},
+		"darkgoldenrod":  {184, 134, 11, 255},
+		"transparent":    {0, 0, 0, 0},
+		"darkred":        {139, 0, 0, 255},
+		"darkgreen":      {0, 100, 0, 255},
+		"darkblue":       {0, 0, 139, 255},
+		"lightblue":      {173, 216, 230, 255},
+		"cornflowerblue": {100, 149, 237, 255},
+	}
+
+	// The bitmap font, for the printable ASCII characters from space onwards.  Each glyph is five columns of seven
+	// pixels, with the lowest bit at the top
+	fontGlyphs = [95][glyphWidth]byte{
+		{0x00, 0x00, 0x00, 0x00, 0x00}, // space
+		{0x00, 0x00, 0x5F, 0x00, 0x00}, // !
+		{0x00, 0x07, 0x00, 0x07, 0x00}, // "
+		{0x14, 0x7F, 0x14, 0x7F, 0x14}, // #
+		{0x24, 0x2A, 0x7F, 0x2A, 0x12}, // $
+		{0x23, 0x13, 0x08, 0x64, 0x62}, // %
+		{0x36, 0x49, 0x55, 0x22, 0x50}, // &
+		{0x00, 0x05, 0x03, 0x00, 0x00}, // '
+		{0x00, 0x1C, 0x22, 0x41, 0x00}, // (
+		{0x00, 0x41, 0x22, 0x1C, 0x00}, // )
+		{0x14, 0x08, 0x3E, 0x08, 0x14}, // *
+		{0x08, 0x08, 0x3E, 0x08, 0x08}, // +
+		{0x00, 0x50, 0x30, 0x00, 0x00}, // ,
+		{0x08, 0x08, 0x08, 0x08, 0x08}, // -
+		{0x00, 0x60, 0x60, 0x00, 0x00}, // .
+		{0x20, 0x10, 0x08, 0x04, 0x02}, // /
+		{0x3E, 0x51, 0x49, 0x45, 0x3E}, // 0
+		{0x00, 0x42, 0x7F, 0x40, 0x00}, // 1
+		{0x42, 0x61, 0x51, 0x49, 0x46}, // 2
+		{0x21, 0x41, 0x45, 0x4B, 0x31}, // 3
+		{0x18, 0x14, 0x12, 0x7F, 0x10}, // 4
+		{0x27, 0x45, 0x45, 0x45, 0x39}, // 5
+		{0x3C, 0x4A, 0x49, 0x49, 0x30}, // 6
+		{0x01, 0x71, 0x09, 0x05, 0x03}, // 7
+		{0x36, 0x49, 0x49, 0x49, 0x36}, // 8
+		{0x06, 0x49, 0x49, 0x29, 0x1E}, // 9
+		{0x00, 0x36, 0x36, 0x00, 0x00}, // :
+		{0x00, 0x56, 0x36, 0x00, 0x00}, // ;
+		{0x08, 0x14, 0x22, 0x41, 0x00}, // <
+		{0x14, 0x14, 0x14, 0x14, 0x14}, // =
+		{0x00, 0x41, 0x22, 0x14, 0x08}, // >
+		{0x02, 0x01, 0x51, 0x09, 0x06}, // ?
+		{0x32, 0x49, 0x79, 0x41, 0x3E}, // @
+		{0x7E, 0x11, 0x11, 0x11, 0x7E}, // A
+		{0x7F, 0x49, 0x49, 0x49, 0x36}, // B
+		{0x3E, 0x41, 0x41, 0x41, 0x22}, // C
+		{0x7F, 0x41, 0x41, 0x22, 0x1C}, // D
+		{0x7F, 0x49, 0x49, 0x49, 0x41}, // E
+		{0x7F, 0x09, 0x09, 0x09, 0x01}, // F
+		{0x3E, 0x41, 0x49, 0x49, 0x7A}, // G
+		{0x7F, 0x08, 0x08, 0x08, 0x7F}, // H
+		{0x00, 0x41, 0x7F, 0x41, 0x00}, // I
+		{0x20, 0x40, 0x41, 0x3F, 0x01}, // J
+		{0x7F, 0x08, 0x14, 0x22, 0x41}, // K
+		{0x7F, 0x40, 0x40, 0x40, 0x40}, // L
+		{0x7F, 0x02, 0x0C, 0x02, 0x7F}, // M
+		{0x7F, 0x04, 0x08, 0x10, 0x7F}, // N
+		{0x3E, 0x41, 0x41, 0x41, 0x3E}, // O
+		{0x7F, 0x09, 0x09, 0x09, 0x06}, // P
+		{0x3E, 0x41, 0x51, 0x21, 0x5E}, // Q
+		{0x7F, 0x09, 0x19, 0x29, 0x46}, // R
+		{0x46, 0x49, 0x49, 0x49, 0x31}, // S
+		{0x01, 0x01, 0x7F, 0x01, 0x01}, // T
+		{0x3F, 0x40, 0x40, 0x40, 0x3F}, // U
+		{0x1F, 0x20, 0x40, 0x20, 0x1F}, // V
+		{0x3F, 0x40, 0x38, 0x40, 0x3F}, // W
+		{0x63, 0x14, 0x08, 0x14, 0x63}, // X
+		{0x07, 0x08, 0x70, 0x08, 0x07}, // Y
+		{0x61, 0x51, 0x49, 0x45, 0x43}, // Z
+		{0x00, 0x7F, 0x41, 0x41, 0x00}, // [
+		{0x02, 0x04, 0x08, 0x10, 0x20}, // backslash
+		{0x00, 0x41, 0x41, 0x7F, 0x00}, // ]
+		{0x04, 0x02, 0x01, 0x02, 0x04}, // ^
+		{0x40, 0x40, 0x40, 0x40, 0x40}, // _
+		{0x00, 0x01, 0x02, 0x04, 0x00}, // `
+		{0x20, 0x54, 0x54, 0x54, 0x78}, // a
+		{0x7F, 0x48, 0x44, 0x44, 0x38}, // b
+		{0x38, 0x44, 0x44, 0x44, 0x20}, // c
+		{0x38, 0x44, 0x44, 0x48, 0x7F}, // d
+		{0x38, 0x54, 0x54, 0x54, 0x18}, // e
+		{0x08, 0x7E, 0x09, 0x01, 0x02}, // f
+		{0x0C, 0x52, 0x52, 0x52, 0x3E}, // g
+		{0x7F, 0x08, 0x04, 0x04, 0x78}, // h
+		{0x00, 0x44, 0x7D, 0x40, 0x00}, // i
+		{0x20, 0x40, 0x44, 0x3D, 0x00}, // j
+		{0x7F, 0x10, 0x28, 0x44, 0x00}, // k
+		{0x00, 0x41, 0x7F, 0x40, 0x00}, // l
+		{0x7C, 0x04, 0x18, 0x04, 0x78}, // m
+		{0x7C, 0x08, 0x04, 0x04, 0x78}, // n
+		{0x38, 0x44, 0x44, 0x44, 0x38}, // o
+		{0x7C, 0x14, 0x14, 0x14, 0x08}, // p
+		{0x08, 0x14, 0x14, 0x18, 0x7C}, // q
+		{0x7C, 0x08, 0x04, 0x04, 0x08}, // r
+		{0x48, 0x54, 0x54, 0x54, 0x20}, // s
+		{0x04, 0x3F, 0x44, 0x40, 0x20}, // t
+		{0x3C, 0x40, 0x40, 0x20, 0x7C}, // u
+		{0x1C, 0x20, 0x40, 0x20, 0x1C}, // v
+		{0x3C, 0x40, 0x30, 0x40, 0x3C}, // w
+		{0x44, 0x28, 0x10, 0x28, 0x44}, // x
+		{0x0C, 0x50, 0x50, 0x50, 0x3C}, // y
+		{0x44, 0x64, 0x54, 0x4C, 0x44}, // z
+		{0x00, 0x08, 0x36, 0x41, 0x00}, // {
+		{0x00, 0x00, 0x7F, 0x00, 0x00}, // |
+		{0x00, 0x41, 0x36, 0x08, 0x00}, // }
+		{0x08, 0x04, 0x08, 0x10, 0x08}, // ~
+	}
+
+	// Superscript digits (as used for powers in the equations) are drawn as smaller, raised normal digits
+	superscripts = map[rune]rune{
+		'⁰': '0', '¹': '1', '²': '2', '³': '3', '⁴': '4', '⁵': '5', '⁶': '6', '⁷': '7', '⁸': '8', '⁹': '9',
+	}
+)
+
+// Begins a new path
+func (r *rasterRenderer) BeginPath() {
+	r.path = nil
+}
+
+// Closes the current sub-path, back to its starting point
+func (r *rasterRenderer) ClosePath() {
+	if n := len(r.path); n > 0 {
+		r.path[n-1].closed = true
+	}
+}
+
+// Adds a whole ellipse to the current path, as a closed sub-path of straight lines
+func (r *rasterRenderer) Ellipse(x float64, y float64, radiusX float64, radiusY float64) {
+	var s rasterSubPath
+	for i := 0; i < ellipseSegments; i++ {
+		a := 2 * math.Pi * float64(i) / ellipseSegments
+		s.points = append(s.points, rasterPoint{X: x + radiusX*math.Cos(a), Y: y + radiusY*math.Sin(a)})
+	}
+	s.closed = true
+	r.path = append(r.path, s)
+}
+
+// Fills the current path with the fill style, using the non-zero winding rule like the canvas does
+func (r *rasterRenderer) Fill() {
+	var polys [][]rasterPoint
+	for _, s := range r.path {
+		if len(s.points) > 2 {
+			polys = append(polys, s.points)
+		}
+	}
+	r.fillPolygons(polys, r.fill)
+}
+
+// Fills a rectangle with the fill style
+func (r *rasterRenderer) FillRect(x float64, y float64, w float64, h float64) {
+	r.fillPolygons([][]rasterPoint{{{x, y}, {x + w, y}, {x + w, y + h}, {x, y + h}}}, r.fill)
+}
+
+// Draws text with the fill style, font, and text alignment.  The Y co-ordinate is the text baseline
+func (r *rasterRenderer) FillText(text string, x float64, y float64) {
+	scale := math.Max(1, math.Round(r.fontSize/(glyphHeight+1)))
+	advance := (glyphWidth + 1) * scale
+	runes := []rune(text)
+	switch r.align {
+	case "center":
+		x -= float64(len(runes)) * advance / 2
+	case "right", "end":
+		x -= float64(len(runes)) * advance
+	}
+
+	// Each lit pixel of a glyph becomes a square, which are all filled together
+	var squares [][]rasterPoint
+	for _, c := range runes {
+		glyphScale, top := scale, y-(glyphHeight*scale)
+		if d, ok := superscripts[c]; ok {
+			c = d
+			glyphScale = math.Max(1, math.Round(scale*2/3))
+		}
+		if c < ' ' || c > '~' {
+			c = '?'
+		}
+		g := fontGlyphs[c-' ']
+		for col := 0; col < glyphWidth; col++ {
+			for row := 0; row < glyphHeight; row++ {
+				if g[col]&(1<<uint(row)) == 0 {
+					continue
+				}
+				px := x + float64(col)*glyphScale
+				py := top + float64(row)*glyphScale
+				w := glyphScale
+				if r.bold {
+					w += math.Max(1, glyphScale/2)
+				}
+				py2 := py + glyphScale
+				squares = append(squares, []rasterPoint{{px, py}, {px + w, py}, {px + w, py2}, {px, py2}})
+			}
+		}
+		x += advance
+	}
+	r.fillPolygons(squares, r.fill)
+}
+
+// Returns the image drawn so far
+func (r *rasterRenderer) Image() *image.RGBA {
+	return r.img
+}
+
+// Adds a straight line to the current path.  With no current sub-path, this starts one instead
+func (r *rasterRenderer) LineTo(x float64, y float64) {
+	n := len(r.path)
+	if n == 0 || r.path[n-1].closed {
+		r.MoveTo(x, y)
+		return
+	}
+	r.path[n-1].points = append(r.path[n-1].points, rasterPoint{X: x, Y: y})
+}
+
+// Starts a new sub-path at the given point
+func (r *rasterRenderer) MoveTo(x float64, y float64) {
+	r.path = append(r.path, rasterSubPath{points: []rasterPoint{{X: x, Y: y}}})
+}
+
+// Sets the colour used for fills and text
+func (r *rasterRenderer) SetFillStyle(colour string) {
+	r.fill = parseColour(colour)
+}
+
+// Sets the font used for text.  Only the size (in px) and boldness are used, as there's just the one font
+func (r *rasterRenderer) SetFont(font string) {
+	r.bold = false
+	for _, f := range strings.Fields(font) {
+		if f == "bold" {
+			r.bold = true
+		}
+		if strings.HasSuffix(f, "px") {
+			if s, err := strconv.ParseFloat(strings.TrimSuffix(f, "px"), 64); err == nil {
+				r.fontSize = s
+			}
+		}
+	}
+}
+
+// Sets the dash pattern for lines.  An empty pattern gives solid lines
+func (r *rasterRenderer) SetLineDash(dash []float64) {
+	r.dash = append([]float64(nil), dash...)
+}
+
+// Sets the width of lines
+func (r *rasterRenderer) SetLineWidth(w float64) {
+	r.lineWidth = w
+}
+
+// Sets the colour used for lines
+func (r *rasterRenderer) SetStrokeStyle(colour string) {
+	r.stroke = parseColour(colour)
+}
+
+// Sets the alignment of text, relative to the point it's drawn at
+func (r *rasterRenderer) SetTextAlign(align string) {
+	r.align = align
+}
+
+// Draws the current path with the stroke style.  Each line segment is drawn as a rectangle of the line width, with
+// the joins between segments filled in
+func (r *rasterRenderer) Stroke() {
+	hw := math.Max(r.lineWidth, 1) / 2
+	var polys [][]rasterPoint
+	for _, s := range r.path {
+		pts := s.points
+		if s.closed && len(pts) > 1 {
+			pts = append(append([]rasterPoint(nil), pts...), pts[0])
+		}
+		for i := 1; i < len(pts); i++ {
+			for _, seg := range r.dashSegments(pts[i-1], pts[i]) {
+				polys = append(polys, lineQuad(seg[0], seg[1], hw))
+			}
+
+			// Fill in the join, so thick lines don't have notches at the corners
+			if hw > 1 && i < len(pts)-1 {
+				polys = append(polys, circlePoly(pts[i], hw))
+			}
+		}
+	}
+	r.fillPolygons(polys, r.stroke)
+}
+
+// Draws the outline of a rectangle with the stroke style
+func (r *rasterRenderer) StrokeRect(x float64, y float64, w float64, h float64) {
+	path := r.path
+	r.path = []rasterSubPath{{points: []rasterPoint{{x, y}, {x + w, y}, {x + w, y + h}, {x, y + h}}, closed: true}}
+	r.Stroke()
+	r.path = path
+}
+
+// Returns a polygon approximating a circle
+func circlePoly(c rasterPoint, radius float64) []rasterPoint {
+	p := make([]rasterPoint, ellipseSegments)
+	for i := range p {
+		a := 2 * math.Pi * float64(i) / ellipseSegments
+		p[i] = rasterPoint{X: c.X + radius*math.Cos(a), Y: c.Y + radius*math.Sin(a)}
+	}
+	return p
+}
+
+// Splits a line into the parts drawn by the dash pattern.  The pattern restarts for each line segment, which is close
+// enough for the short dashed lines drawn here
+func (r *rasterRenderer) dashSegments(a rasterPoint, b rasterPoint) (segs [][2]rasterPoint) {
+	length := math.Hypot(b.X-a.X, b.Y-a.Y)
+	total := float64(0)
+	for _, d := range r.dash {
+		total += d
+	}
+	if len(r.dash) == 0 || total <= 0 || length == 0 {
+		return [][2]rasterPoint{{a, b}}
+	}
+	at := func(t float64) rasterPoint {
+		return rasterPoint{X: a.X + (b.X-a.X)*t/length, Y: a.Y + (b.Y-a.Y)*t/length}
+	}
+	pos := float64(0)
+	for i := 0; pos < length; i++ {
+		d := r.dash[i%len(r.dash)]
+		if i%2 == 0 {
+			segs = append(segs, [2]rasterPoint{at(pos), at(math.Min(pos+d, length))})
+		}
+		pos += d
+	}
+	return
+}
+
+// Fills polygons with a colour, using the non-zero winding rule.  Pixels are filled if their centres are inside, and
+// the polygons are combined into one shape first, so overlapping parts of a translucent shape aren't drawn twice
+func (r *rasterRenderer) fillPolygons(polys [][]rasterPoint, c color.RGBA) {
+	if len(polys) == 0 || c.A == 0 {
+		return
+	}
+
+	// Work out which part of the image the polygons cover
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range polys {
+		for _, q := range p {
+			minX, minY = math.Min(minX, q.X), math.Min(minY, q.Y)
+			maxX, maxY = math.Max(maxX, q.X), math.Max(maxY, q.Y)
+		}
+	}
+	area := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX))+1, int(math.Ceil(maxY))+1)
+	area = area.Intersect(r.img.Bounds())
+	if area.Empty() {
+		return
+	}
+
+	// Build a mask of the covered pixels, one row at a time
+	type crossing struct {
+		x   float64
+		dir int
+	}
+	mask := image.NewAlpha(area)
+	var xs []crossing
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		cy := float64(y) + 0.5
+		xs = xs[:0]
+		for _, p := range polys {
+			for i := range p {
+				a, b := p[i], p[(i+1)%len(p)]
+				if (a.Y <= cy) == (b.Y <= cy) {
+					continue
+				}
+				dir := 1
+				if b.Y < a.Y {
+					dir = -1
+				}
+				xs = append(xs, crossing{x: a.X + (cy-a.Y)*(b.X-a.X)/(b.Y-a.Y), dir: dir})
+			}
+		}
+		sort.Slice(xs, func(i, j int) bool { return xs[i].x < xs[j].x })
+		winding := 0
+		for i := 0; i < len(xs)-1; i++ {
+			winding += xs[i].dir
+			if winding == 0 {
+				continue
+			}
+			start := int(math.Max(math.Ceil(xs[i].x-0.5), float64(area.Min.X)))
+			end := int(math.Min(math.Ceil(xs[i+1].x-0.5), float64(area.Max.X)))
+			for x := start; x < end; x++ {
+				mask.SetAlpha(x, y, color.Alpha{A: 255})
+			}
+		}
+	}
+	draw.DrawMask(r.img, area, image.NewUniform(c), image.ZP, mask, area.Min, draw.Over)
+}
+
+// Returns the rectangle covered by a line of the given half width, between two points
+func lineQuad(a rasterPoint, b rasterPoint, hw float64) []rasterPoint {
+	length := math.Hypot(b.X-a.X, b.Y-a.Y)
+	if length == 0 {
+		return nil
+	}
+	nx, ny := -(b.Y-a.Y)/length*hw, (b.X-a.X)/length*hw
+	return []rasterPoint{{a.X + nx, a.Y + ny}, {b.X + nx, b.Y + ny}, {b.X - nx, b.Y - ny}, {a.X - nx, a.Y - ny}}
+}
+
+// Returns a new raster renderer, drawing into a blank (transparent) image of the given size
+func newRasterRenderer(w int, h int) *rasterRenderer {
+	return &rasterRenderer{
+		img:       image.NewRGBA(image.Rect(0, 0, w, h)),
+		fill:      colourNames["black"],
+		stroke:    colourNames["black"],
+		lineWidth: 1,
+		fontSize:  10,
+		align:     "start",
+	}
+}
+
+// Returns the colour for a CSS colour string.  Named colours, #rgb, #rrggbb, rgb(), and rgba() are understood.
+// Anything else gives black.  A canvas would ignore the colour and keep using the previous one instead, but black is
+// the default colour a canvas starts with
+func parseColour(s string) color.RGBA {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := colourNames[s]; ok {
+		return c
+	}
+	if strings.HasPrefix(s, "#") {
+		h := s[1:]
+		if len(h) == 3 {
+			h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]})
+		}
+		if v, err := strconv.ParseUint(h, 16, 32); err == nil && len(h) == 6 {
+			return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}
+		}
+		return colourNames["black"]
+	}
+	if i := strings.Index(s, "("); i > 0 && strings.HasSuffix(s, ")") && strings.HasPrefix(s, "rgb") {
+		parts := strings.Split(s[i+1:len(s)-1], ",")
+		if len(parts) < 3 || len(parts) > 4 {
+			return colourNames["black"]
+		}
+		var v [4]float64
+		v[3] = 1
+		for j, p := range parts {
+			f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+			if err != nil {
+				return colourNames["black"]
+			}
+			v[j] = f
+		}
+
+		// The image uses premultiplied alpha
+		a := math.Max(0, math.Min(1, v[3]))
+		c := func(f float64) uint8 {
+			return uint8(math.Max(0, math.Min(255, f)) * a)
+		}
+		return color.RGBA{R: c(v[0]), G: c(v[1]), B: c(v[2]), A: uint8(a * 255)}
+	}
+	return colourNames["black"]
+}
+
+// Draws a scene snapshot into a new image of the given size, without needing a browser.  Large images have their line
+// widths and text scaled up to match
+func renderImage(sc *scene, w int, h int) *image.RGBA {
+	r := newRasterRenderer(w, h)
+	drawExport(r, sc, float64(w), float64(h))
+	return r.Image()
+}
+
+// Draws a scene snapshot at the given size, and writes it out as a PNG image
+func renderPNG(out io.Writer, sc *scene, w int, h int) error {
+	if w <= 0 || h <= 0 {
+		return fmt.Errorf("invalid image size: %dx%d", w, h)
+	}
+	return png.Encode(out, renderImage(sc, w, h))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "Rewrite the known good images in testdata with the ones rendered")
+
+// Returns a fixed scene to render, which doesn't depend on the equation evaluator or any global state: the curve
+// y = x³/3, with its axes and the XY grid, seen from the given view
+func testScene(v PresetView) *scene {
+	g := Object{Name: "Equation", C: "blue", Eq: "y = x³/3"}
+	for i := -30; i <= 30; i++ {
+		x := float64(i) / 10
+		g.P = append(g.P, Point{X: x, Y: x * x * x / 3})
+	}
+	d := objectDomain([]Object{g})
+	objs := generateGrid(GRIDXY, d, 1, Point{})
+	objs = append(objs, generateAxes(d, 1, Point{}), g)
+	return &scene{objects: objs, view: presetRotation(v), opText: "Test."}
+}
+
+func TestRenderImage(t *testing.T) {
+	tests := []struct {
+		file string
+		view PresetView
+		w, h int
+	}{
+		{"top.png", TOPVIEW, 600, 400},
+		{"iso.png", ISOVIEW, 600, 400},
+
+		// Bigger than the export base size, so the line widths and text are scaled up
+		{"iso-large.png", ISOVIEW, 1800, 1200},
+	}
+	for _, tc := range tests {
+		t.Run(tc.file, func(t *testing.T) {
+			got := renderImage(testScene(tc.view), tc.w, tc.h)
+			path := filepath.Join("testdata", tc.file)
+			if *updateGolden {
+				var b bytes.Buffer
+				if err := png.Encode(&b, got); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(path, b.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			img, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := image.NewRGBA(img.Bounds())
+			for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+				for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+					want.Set(x, y, img.At(x, y))
+				}
+			}
+			if got.Bounds() != want.Bounds() {
+				t.Fatalf("image is %v, want %v", got.Bounds(), want.Bounds())
+			}
+			if diff := countDiffs(got, want); diff > 0 {
+				t.Errorf("%d pixels differ from %s.  If the change is intended, rerun with -update", diff, path)
+			}
+		})
+	}
+}
+
+func TestParseColour(t *testing.T) {
+	tests := []struct {
+		s    string
+		want [4]uint8
+	}{
+		{"black", [4]uint8{0, 0, 0, 255}},
+		{" Blue ", [4]uint8{0, 0, 255, 255}},
+		{"#f80", [4]uint8{255, 136, 0, 255}},
+		{"#123456", [4]uint8{0x12, 0x34, 0x56, 255}},
+		{"rgb(10, 20, 30)", [4]uint8{10, 20, 30, 255}},
+		{"nonsense", [4]uint8{0, 0, 0, 255}},
+		{"#12345", [4]uint8{0, 0, 0, 255}},
+	}
+	for _, tc := range tests {
+		c := parseColour(tc.s)
+		if got := [4]uint8{c.R, c.G, c.B, c.A}; got != tc.want {
+			t.Errorf("parseColour(%q) = %v, want %v", tc.s, got, tc.want)
+		}
+	}
+}
+
+// Returns the number of pixels which differ between two images of the same size
+func countDiffs(a *image.RGBA, b *image.RGBA) (n int) {
+	for i := 0; i+3 < len(a.Pix) && i+3 < len(b.Pix); i += 4 {
+		if !bytes.Equal(a.Pix[i:i+4], b.Pix[i:i+4]) {
+			n++
+		}
+	}
+	return
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// The help text shown in the information area, one line at a time
+var helpText = []string{
+	"Use wasd/numpad keys or drag to rotate,",
+	"mouse wheel to zoom, right drag or",
+	"shift + drag/arrow keys to pan,",
+	"ctrl + drag to zoom into an area.",
+	"Ctrl+Z/Ctrl+Y undo/redo, 0 resets.",
+	"Alt + click sets the pivot, c resets it.",
+	"t/f/r/i for top/front/side/iso views.",
+	"p turntable, x/y/z axis, [ ] speed.",
+	"e/q zoom, g/h/v hide grid/axes/derivs.",
+}
+
+// The optional parts of the information area.  These are bit flags, so any combination of them can be drawn
+type InfoPart int
+
+const (
+	INFOHELP      InfoPart = 1 << iota // The help text about the controls
+	INFOSOURCE                         // The link to the source code
+	INFOHIGHLIGHT                      // Draws the source code link in bold, for when the mouse is over it
+
+	// The parts drawn on screen
+	INFOSCREEN = INFOHELP | INFOSOURCE
+
+	// The parts drawn in exported images and animations, in every format.  These usually end up in other documents,
+	// so only the graph and its equations are drawn, without the help text and source code link
+	INFOEXPORT InfoPart = 0
+)
+
+// The drawing operations a scene is rendered with.  These follow the HTML5 canvas 2D context closely, so colours,
+// fonts, and text alignment are given the same way as for the canvas (eg "blue", "rgb(200, 200, 200)", "bold 14px
+// serif", and "left").  Scene drawing code only uses this, so it can draw onto anything with an implementation
+type Renderer interface {
+	SetFillStyle(colour string)
+	SetStrokeStyle(colour string)
+	SetLineWidth(w float64)
+	SetLineDash(dash []float64)
+	SetFont(font string)
+	SetTextAlign(align string)
+
+	BeginPath()
+	MoveTo(x float64, y float64)
+	LineTo(x float64, y float64)
+	Ellipse(x float64, y float64, radiusX float64, radiusY float64) // Adds a whole ellipse to the path
+	ClosePath()
+	Fill()
+	Stroke()
+
+	FillRect(x float64, y float64, w float64, h float64)
+	StrokeRect(x float64, y float64, w float64, h float64)
+	FillText(text string, x float64, y float64)
+}
+
+// Draws the surfaces, edges, and graph lines of a scene snapshot.  This is the part of the scene which grows with the
+// size of the meshes, and which other backends (eg WebGL) can draw instead
+func drawGeometry(r Renderer, sc *scene, w float64, h float64) {
+	viewSpace := transformObjects(sc.view, sc.objects)
+	centerX := w * 0.75 / 2
+	centerY := (h - 1) / 2
+
+	// The number of pixels per world space unit
+	step := pixelsPerUnitAt(w, h)
+
+	// Draw the grid and axes
+	var pointX, pointY float64
+	r.SetLineWidth(1)
+	r.SetLineDash(nil)
+	for _, o := range viewSpace {
+		// Draw the surfaces
+		r.SetFillStyle(o.C)
+		for _, l := range o.S {
+			for m, n := range l {
+				pointX = o.P[n].X
+				pointY = o.P[n].Y
+				if m == 0 {
+					r.BeginPath()
+					r.MoveTo(centerX+(pointX*step), centerY+((pointY*step)*-1))
+				} else {
+					r.LineTo(centerX+(pointX*step), centerY+((pointY*step)*-1))
+				}
+			}
+			r.ClosePath()
+			r.Fill()
+		}
+
+		// Draw the edges
+		r.SetStrokeStyle(o.C)
+		var point1X, point1Y, point2X, point2Y float64
+		for _, l := range o.E {
+			point1X = o.P[l[0]].X
+			point1Y = o.P[l[0]].Y
+			point2X = o.P[l[1]].X
+			point2Y = o.P[l[1]].Y
+			r.BeginPath()
+			r.MoveTo(centerX+(point1X*step), centerY+((point1Y*step)*-1))
+			r.LineTo(centerX+(point2X*step), centerY+((point2Y*step)*-1))
+			r.Stroke()
+		}
+	}
+
+	// Draw the graph and derivatives
+	r.SetLineWidth(2)
+	r.SetLineDash(nil)
+	var px, py float64
+	for _, o := range viewSpace {
+		if isGraph(o) {
+			// Draw lines between the points
+			r.SetStrokeStyle(o.C)
+			r.BeginPath()
+			for k, l := range o.P {
+				px = centerX + (l.X * step)
+				py = centerY + ((l.Y * step) * -1)
+				if k == 0 {
+					r.MoveTo(px, py)
+				} else {
+					r.LineTo(px, py)
+				}
+			}
+			r.Stroke()
+
+			// Draw dots for the points
+			r.SetFillStyle("black")
+			for _, l := range o.P {
+				px = centerX + (l.X * step)
+				py = centerY + ((l.Y * step) * -1)
+				r.BeginPath()
+				r.Ellipse(px, py, 1, 1)
+				r.Fill()
+				r.Stroke()
+			}
+		}
+	}
+}
+
+// Draws the information area on the right, and the border around the graph area.  The operation text and the graph
+// equations are always drawn, along with whichever of the optional parts are given
+func drawInfo(r Renderer, sc *scene, w float64, h float64, parts InfoPart) {
+	// Setup useful variables
+	border := float64(2)
+	gap := float64(3)
+	top := border + gap
+	gw := w * 0.75
+	gh := h - 1
+
+	// Clear the information area (right side)
+	r.SetFillStyle("white")
+	r.FillRect(gw+1, 0, w, h)
+
+	// Draw the text describing the current operation
+	textY := top + 20
+	r.SetFillStyle("black")
+	r.SetFont("bold 14px serif")
+	r.SetTextAlign("left")
+	r.FillText("Operation:", gw+20, textY)
+	textY += 20
+	r.SetFont("14px sans-serif")
+	r.FillText(sc.opText, gw+20, textY)
+	textY += 30
+
+	// Add the help text about control keys and mouse zoom
+	if parts&INFOHELP != 0 {
+		r.SetFillStyle("blue")
+		r.SetFont("14px sans-serif")
+		for _, l := range helpText {
+			r.FillText(l, gw+20, textY)
+			textY += 20
+		}
+		textY += 10
+	}
+
+	// Add the graph and derivatives information
+	r.SetFillStyle("black")
+	for _, o := range sc.objects {
+		if isGraph(o) {
+			r.SetFont("bold 18px serif")
+			r.FillText(o.Name, gw+20, textY)
+			textY += 20
+			r.SetFont("16px sans-serif")
+			r.FillText(o.Eq, gw+40, textY)
+			textY += 30
+		}
+	}
+
+	// Clear the source code link area, and add the URL to the source code
+	if parts&INFOSOURCE != 0 {
+		r.SetFillStyle("white")
+		r.FillRect(gw+1, gh-55, w, h)
+		r.SetFillStyle("black")
+		r.SetFont("bold 14px serif")
+		r.FillText("Source code:", gw+20, gh-35)
+		r.SetFillStyle("blue")
+		if parts&INFOHIGHLIGHT != 0 {
+			r.SetFont("bold 12px sans-serif")
+		} else {
+			r.SetFont("12px sans-serif")
+		}
+		r.FillText(sourceURL, gw+20, gh-15)
+	}
+
+	// Draw a border around the graph area
+	r.SetLineDash(nil)
+	r.SetLineWidth(2)
+	r.SetStrokeStyle("white")
+	r.BeginPath()
+	r.MoveTo(0, 0)
+	r.LineTo(w, 0)
+	r.LineTo(w, h)
+	r.LineTo(0, h)
+	r.ClosePath()
+	r.Stroke()
+	r.SetLineWidth(2)
+	r.SetStrokeStyle("black")
+	r.BeginPath()
+	r.MoveTo(border, border)
+	r.LineTo(gw, border)
+	r.LineTo(gw, gh)
+	r.LineTo(border, gh)
+	r.ClosePath()
+	r.Stroke()
+}
+
+// Draws the point labels and pivot point of a scene snapshot.  Only the labelled points are transformed, so this
+// stays quick for large meshes
+func drawLabels(r Renderer, sc *scene, w float64, h float64) {
+	centerX := w * 0.75 / 2
+	centerY := (h - 1) / 2
+
+	// The number of pixels per world space unit
+	step := pixelsPerUnitAt(w, h)
+
+	// Draw any point labels
+	r.SetFillStyle("black")
+	r.SetFont("bold 16px serif")
+	var px, py float64
+	for _, o := range sc.objects {
+		for _, l := range o.P {
+			if l.Label != "" {
+				p := transform(sc.view, l)
+				r.SetTextAlign(l.LabelAlign)
+				px = centerX + (p.X * step)
+				py = centerY + ((p.Y * step) * -1)
+				r.FillText(l.Label, px, py)
+			}
+		}
+	}
+
+	// Mark the pivot point, if the user has chosen one
+	if sc.pivot != nil {
+		p := transform(sc.view, *sc.pivot)
+		px, py := centerX+(p.X*step), centerY+((p.Y*step)*-1)
+		r.SetLineWidth(2)
+		r.SetStrokeStyle("magenta")
+		r.BeginPath()
+		r.MoveTo(px-6, py)
+		r.LineTo(px+6, py)
+		r.MoveTo(px, py-6)
+		r.LineTo(px, py+6)
+		r.Stroke()
+	}
+
+}
+
+// Draws a scene snapshot with a renderer, at the given size in pixels.  The graph takes up the left three quarters,
+// with the information area on the right, showing the given optional parts.  Only the scene itself is drawn, without
+// anything the mouse is doing on screen (such as a zoom box being dragged out), so this suits exported images
+func drawScene(r Renderer, sc *scene, w float64, h float64, parts InfoPart) {
+	// Clear the background
+	r.SetFillStyle("white")
+	r.FillRect(0, 0, w, h)
+
+	drawGeometry(r, sc, w, h)
+	drawLabels(r, sc, w, h)
+	drawInfo(r, sc, w, h, parts)
+}
+
+// Draws a zoom box being dragged out, between the given corners in canvas co-ordinates
+func drawZoomBox(r Renderer, x [2]float64, y [2]float64) {
+	r.SetLineWidth(1)
+	r.SetStrokeStyle("black")
+	r.SetLineDash([]float64{4, 4})
+	r.StrokeRect(x[0], y[0], x[1]-x[0], y[1]-y[0])
+	r.SetLineDash(nil)
+}
+
+// Returns a key for the information area of a scene snapshot, which changes whenever anything drawInfo draws does
+func infoKey(sc *scene, parts InfoPart) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d\x00%s", parts, sc.opText)
+	for _, o := range sc.objects {
+		if isGraph(o) {
+			fmt.Fprintf(&b, "\x00%s\x00%s", o.Name, o.Eq)
+		}
+	}
+	return b.String()
+}
+
+// Returns the number of pixels per world space unit, for a canvas of the given size
+func pixelsPerUnitAt(w float64, h float64) float64 {
+	return math.Min(w, h) / 30
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDrawSceneLeavesOutScreenState(t *testing.T) {
+	sc := testScene(ISOVIEW)
+	want := recordingRenderer{}
+	drawScene(&want, sc, 900, 600, INFOSCREEN)
+
+	// A zoom box being dragged out and the mouse over the source code link only show on screen, so they mustn't
+	// change what's drawn for an export
+	defer func() {
+		zoomBoxActive, highLightSource = false, false
+	}()
+	zoomBoxActive, highLightSource = true, true
+	zoomBoxX, zoomBoxY = [2]float64{10, 200}, [2]float64{20, 300}
+	got := recordingRenderer{}
+	drawScene(&got, sc, 900, 600, INFOSCREEN)
+	if !reflect.DeepEqual(got.calls, want.calls) {
+		t.Errorf("drawing changed with the zoom box and source code link highlight")
+	}
+
+	// The information area key has to change with the highlight, so it gets redrawn
+	if infoKey(sc, INFOSCREEN|INFOHIGHLIGHT) == infoKey(sc, INFOSCREEN) {
+		t.Errorf("infoKey doesn't change with the source code link highlight")
+	}
+}
+
+func TestExportsLeaveOutScreenText(t *testing.T) {
+	sc := testScene(ISOVIEW)
+	r := recordingRenderer{}
+	drawExport(&r, sc, 1800, 1200)
+	tests := []struct {
+		name string
+		out  []byte
+		text func(s string) []byte // Returns text the way it appears in the output
+	}{
+		{"SVG", renderSVG(sc, 900, 600), func(s string) []byte { return []byte(svgEscape(s)) }},
+
+		// PNG images, GIFs, and frames are all drawn with drawExport
+		{"PNG", []byte(strings.Join(r.calls, "\n")), func(s string) []byte { return []byte(s) }},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// The equation is drawn, but not the help text or source code link
+			for _, s := range []string{"Operation:", "y = x³/3"} {
+				if !bytes.Contains(tc.out, tc.text(s)) {
+					t.Errorf("export is missing %q", s)
+				}
+			}
+			for _, s := range []string{helpText[0], "Source code:", sourceURL} {
+				if bytes.Contains(tc.out, tc.text(s)) {
+					t.Errorf("export has %q in it", s)
+				}
+			}
+		})
+	}
+}
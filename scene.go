@@ -0,0 +1,96 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// A snapshot of everything the renderer needs from the world space.  Snapshots are never changed once published, so
+// the renderer can draw one while the next is being put together elsewhere
+type scene struct {
+	objects []Object
+	view    matrix
+	opText  string
+	pivot   *Point // The pivot point, if the user has chosen one
+	gen     uint64 // Counts up by one for each snapshot published, so every snapshot has a different one
+}
+
+var (
+	// Protects the world space, view matrix, operation text, animations, pending operations, and history.  Changes to
+	// any of these are made with it held, then published as a new scene snapshot
+	sceneLock sync.Mutex
+
+	// The most recently published scene snapshot, and the generation number of the next one
+	snapshot atomic.Value
+	nextGen  uint64
+
+	// Set to 1 when something has changed which needs the canvas redrawing.  This is set from event handlers and
+	// goroutines as well as the frame renderer, so it's only accessed atomically
+	sceneDirty int32 = 1
+
+	// The kinds of object the user has hidden.  Hidden objects are left out of the scene snapshots
+	hideAxes        bool
+	hideGrid        bool
+	hideDerivatives bool
+)
+
+// Returns the most recently published scene snapshot.  This doesn't need the scene lock, so it never blocks
+func currentScene() *scene {
+	sc, ok := snapshot.Load().(*scene)
+	if !ok {
+		return &scene{view: identityMatrix}
+	}
+	return sc
+}
+
+// Returns true if the object is one of a kind the user has hidden.  The scene lock must be held by the caller
+func isHidden(o Object) bool {
+	switch {
+	case o.Name == "axes":
+		return hideAxes
+	case o.Name == "grid":
+		return hideGrid
+	case isGraph(o) && o.Name != "Equation":
+		return hideDerivatives
+	}
+	return false
+}
+
+// Marks the canvas as needing to be redrawn on the next frame
+func markDirty() {
+	atomic.StoreInt32(&sceneDirty, 1)
+}
+
+// Publishes a new scene snapshot, from the current world space and view matrix.  The scene lock must be held by the
+// caller
+func publishScene() {
+	// The objects themselves are replaced rather than changed when the world space is updated, so copying the list of
+	// them is enough to keep the snapshot separate
+	objs := make([]Object, 0, len(worldSpace))
+	for _, o := range worldSpace {
+		if !isHidden(o) {
+			objs = append(objs, o)
+		}
+	}
+	nextGen++
+	sc := &scene{objects: objs, view: viewMatrix, opText: opText, gen: nextGen}
+	if pivotSet {
+		p := pivotPoint
+		sc.pivot = &p
+	}
+	snapshot.Store(sc)
+	markDirty()
+}
+
+// Returns true if the canvas needs to be redrawn, clearing the flag ready for the next frame
+func takeDirty() bool {
+	return atomic.SwapInt32(&sceneDirty, 0) == 1
+}
+
+// Shows or hides a kind of object, given its hidden flag
+func toggleObjects(hide *bool) {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	*hide = !*hide
+	publishScene()
+}
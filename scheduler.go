@@ -0,0 +1,144 @@
+package main
+
+import "math"
+
+const (
+	// Rotations are only combined while the rotation left to animate is less than this many degrees.  An animation takes
+	// the shortest way round to its target, so anything from half a turn on would be lost, or turn the wrong way
+	maxMergedRotation = 180
+)
+
+// Operations waiting to be animated.  These are protected by sceneLock, the same as the animation in progress
+var pending []Operation
+
+// Stops the animation in progress where it is, and throws away any pending operations
+func cancelOperations() {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	stopOperations()
+	opText = "Cancelled."
+	publishScene()
+}
+
+// Finishes off the animation in progress, recording it in the history.  The scene lock must be held by the caller
+func finishOperation() {
+	op := anim.op
+	recordHistory(op, anim.before, viewMatrix)
+	anim = nil
+
+	// The zoom level or the part of the graph in view may have changed, so regenerate the axes and grid to suit
+	updateAxes()
+	updateGrid()
+
+	// Different parts of the graph may be visible now, so move the default pivot point to suit
+	if op.Op != ROTATE {
+		updatePivot()
+	}
+	opText = "Complete."
+}
+
+// Combines two operations of the same type into one, which has the effect of a followed by b.  Returns false if they
+// can't be combined, which is always the case for VIEW operations.  Rotations are only combined when they're around
+// the same single axis, as rotations around different axes don't add up like their angles do
+func mergeOperations(a Operation, b Operation) (Operation, bool) {
+	if a.Op != b.Op || a.Op == VIEW {
+		return a, false
+	}
+	if a.Op == ROTATE {
+		axisA, axisB := rotationAxis(a), rotationAxis(b)
+		if axisA < 0 || axisB < 0 || (axisA != axisB && axisA != 3 && axisB != 3) {
+			return a, false
+		}
+	}
+	m := a
+	m.T = int32(math.Max(float64(a.T), float64(b.T)))
+	switch a.Op {
+	case ROTATE:
+		m.X, m.Y, m.Z = a.X+b.X, a.Y+b.Y, a.Z+b.Z
+	case SCALE:
+		m.X, m.Y, m.Z = a.X*b.X, a.Y*b.Y, a.Z*b.Z
+	case TRANSLATE:
+		m.X, m.Y, m.Z = a.X+b.X, a.Y+b.Y, a.Z+b.Z
+	case ZOOM:
+		m.S = a.S * b.S
+		m.X, m.Y, m.Z = (a.X*b.S)+b.X, (a.Y*b.S)+b.Y, (a.Z*b.S)+b.Z
+	}
+	return m, true
+}
+
+// Starts the next pending operation, returning false if there isn't one.  The scene lock must be held by the caller
+func nextOperation() bool {
+	if len(pending) == 0 {
+		return false
+	}
+	op := pending[0]
+	pending = pending[1:]
+	startAnimation(op)
+	return true
+}
+
+// Returns the angle of a rotation around a single axis, in degrees
+func rotationAngle(op Operation) float64 {
+	if op.Op != ROTATE {
+		return 0
+	}
+	return op.X + op.Y + op.Z
+}
+
+// Returns which axis a rotation is around: 0, 1, or 2 for X, Y, or Z, 3 if it doesn't rotate at all, or -1 if it
+// rotates around more than one axis
+func rotationAxis(op Operation) int {
+	axis := 3
+	for i, v := range []float64{op.X, op.Y, op.Z} {
+		if v != 0 {
+			if axis != 3 {
+				return -1
+			}
+			axis = i
+		}
+	}
+	return axis
+}
+
+// Stops the animation in progress where it is, recording how far it got in the history, and throws away any pending
+// operations.  The scene lock must be held by the caller
+func stopOperations() {
+	if anim != nil {
+		finishOperation()
+	}
+	pending = nil
+}
+
+// Schedules an operation to be animated.  This never blocks, so it's safe to call from the javascript callbacks.
+//
+// If an operation of the same type is already being animated, it's retargeted to include the new operation, and
+// carries on smoothly from wherever it's got to.  Otherwise the new operation is added to the pending list, merging
+// it with the last pending operation if that's of the same type.  So (for example) two rotate steps in quick
+// succession become one bigger rotation.  Rotations stop being combined once they'd have half a turn left to go, so
+// holding down a rotate key queues up a series of rotations instead
+func submitOperation(op Operation) {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	recordStep(op)
+
+	// Retarget the animation in progress
+	if anim != nil && len(pending) == 0 {
+		left := anim.left*(1-anim.progress(clock.Now())) + rotationAngle(op)
+		if merged, ok := mergeOperations(anim.op, op); ok && (op.Op != ROTATE || math.Abs(left) < maxMergedRotation) {
+			retarget(operationTarget(op, anim.end))
+			anim.op, anim.left = merged, left
+			opText = operationText(merged)
+			return
+		}
+	}
+
+	// Merge with the last pending operation
+	if n := len(pending); n > 0 {
+		merged, ok := mergeOperations(pending[n-1], op)
+		if ok && (op.Op != ROTATE || math.Abs(rotationAngle(merged)) < maxMergedRotation) {
+			pending[n-1] = merged
+			return
+		}
+	}
+	pending = append(pending, op)
+}
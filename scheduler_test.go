@@ -0,0 +1,163 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// Sets up the scene for a scheduler test, with the test graph, the identity view, the pivot at the origin, and nothing
+// being animated.  The animations are run against a manual clock, which is returned along with a function putting the
+// original clock back
+func schedulerScene() (*manualClock, func()) {
+	saved := clock
+	c := &manualClock{}
+	sc := testScene(TOPVIEW)
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	clock = c
+	worldSpace = sc.objects
+	graphDomain = objectDomain(sc.objects[len(sc.objects)-1:])
+	viewMatrix = identityMatrix
+	pivotPoint, pivotSet = Point{}, false
+	anim, pending, player = nil, nil, nil
+	undoStack, redoStack = nil, nil
+	publishScene()
+	return c, func() {
+		sceneLock.Lock()
+		defer sceneLock.Unlock()
+		clock = saved
+	}
+}
+
+// Returns the angle the view is turned around the Z axis, in degrees from -180 to 180
+func viewAngleZ() float64 {
+	p := transform(currentScene().view, Point{X: 1})
+	return math.Atan2(p.Y, p.X) * 180 / math.Pi
+}
+
+// Returns the difference between two angles in degrees, the short way round
+func angleDiff(a float64, b float64) float64 {
+	d := math.Mod(a-b, 360)
+	if d > 180 {
+		d -= 360
+	} else if d <= -180 {
+		d += 360
+	}
+	return d
+}
+
+func TestMergeOperations(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Operation
+		want Operation
+		ok   bool
+	}{
+		{"rotations around the same axis", Operation{Op: ROTATE, T: 250, Z: 25}, Operation{Op: ROTATE, T: 500, Z: -10},
+			Operation{Op: ROTATE, T: 500, Z: 15}, true},
+		{"rotations around different axes", Operation{Op: ROTATE, X: 25}, Operation{Op: ROTATE, Y: 25},
+			Operation{}, false},
+		{"rotation around two axes", Operation{Op: ROTATE, X: 25, Y: 25}, Operation{Op: ROTATE, X: 25},
+			Operation{}, false},
+		{"rotation which doesn't rotate", Operation{Op: ROTATE}, Operation{Op: ROTATE, Y: 25},
+			Operation{Op: ROTATE, Y: 25}, true},
+		{"scales", Operation{Op: SCALE, X: 2, Y: 3, Z: 4}, Operation{Op: SCALE, X: 0.5, Y: 2, Z: 1},
+			Operation{Op: SCALE, X: 1, Y: 6, Z: 4}, true},
+		{"translations", Operation{Op: TRANSLATE, X: 1, Y: 2}, Operation{Op: TRANSLATE, X: 3, Z: -1},
+			Operation{Op: TRANSLATE, X: 4, Y: 2, Z: -1}, true},
+
+		// The second zoom scales the offset of the first one too
+		{"zooms", Operation{Op: ZOOM, S: 2, X: 1}, Operation{Op: ZOOM, S: 3, Y: 1},
+			Operation{Op: ZOOM, S: 6, X: 3, Y: 1}, true},
+		{"views", Operation{Op: VIEW}, Operation{Op: VIEW}, Operation{}, false},
+		{"different types", Operation{Op: ROTATE, Z: 25}, Operation{Op: TRANSLATE, X: 1}, Operation{}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := mergeOperations(tc.a, tc.b)
+			if ok != tc.ok {
+				t.Fatalf("mergeOperations() ok = %v, want %v", ok, tc.ok)
+			}
+			if ok && (got.Op != tc.want.Op || got.T != tc.want.T || got.S != tc.want.S || got.X != tc.want.X ||
+				got.Y != tc.want.Y || got.Z != tc.want.Z) {
+				t.Errorf("mergeOperations() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRetargetKeepsSpeed(t *testing.T) {
+	c, restore := schedulerScene()
+	defer restore()
+	op := Operation{Op: ROTATE, T: 300, E: EASEINOUT, Z: 25}
+	submitOperation(op)
+	stepAnimation(c.Now())
+
+	// Halfway through, the rotation is turning at its fastest
+	c.Advance(134)
+	stepAnimation(c.Now())
+	before := viewAngleZ()
+	c.Advance(16)
+	stepAnimation(c.Now())
+	speed := angleDiff(viewAngleZ(), before)
+
+	// Retargeting carries on at least as fast, rather than easing in again from a standstill
+	submitOperation(op)
+	before = viewAngleZ()
+	c.Advance(16)
+	stepAnimation(c.Now())
+	if s := angleDiff(viewAngleZ(), before); s < speed {
+		t.Errorf("turned %v° in the frame after being retargeted, down from %v°", s, speed)
+	}
+
+	// And it still ends up at the combined rotation
+	c.Advance(1000)
+	stepAnimation(c.Now())
+	if a := viewAngleZ(); math.Abs(a-50) > 1e-9 {
+		t.Errorf("view turned %v°, want 50°", a)
+	}
+}
+
+func TestHeldRotateKey(t *testing.T) {
+	c, restore := schedulerScene()
+	defer restore()
+	op := Operation{Op: ROTATE, T: 250, E: EASEINOUT, Z: 25}
+
+	// Hold the key down for a second, with it repeating every 33ms and a frame every 16ms.  The view has to keep
+	// turning the same way all the while, and end up turned by every key press
+	var turned, last float64
+	frame := func() {
+		stepAnimation(c.Now())
+		d := angleDiff(viewAngleZ(), last)
+		if d < -1e-9 {
+			t.Fatalf("view turned back %v° at %vms", -d, c.Now())
+		}
+		turned += d
+		last = viewAngleZ()
+	}
+	presses := 0
+	for ms := 0.0; ms < 1000; ms += 16 {
+		for float64(presses)*33 <= ms {
+			submitOperation(op)
+			presses++
+		}
+		frame()
+		c.Advance(16)
+	}
+	if turned < 180 {
+		t.Errorf("view only turned %v° while the key was held down", turned)
+	}
+	for i := 0; i < 1000; i++ {
+		frame()
+		c.Advance(16)
+	}
+	sceneLock.Lock()
+	done := anim == nil && len(pending) == 0
+	sceneLock.Unlock()
+	if !done {
+		t.Fatal("rotations still going after the key was let go")
+	}
+	if want := float64(presses) * 25; math.Abs(turned-want) > 1e-6 {
+		t.Errorf("view turned %v°, want %v°", turned, want)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A renderer which writes the scene out as an SVG document.  Everything drawn becomes a vector element, so the result
+// stays sharp at any size
+type svgRenderer struct {
+	w, h      float64
+	body      bytes.Buffer
+	path      strings.Builder
+	fill      string
+	stroke    string
+	lineWidth float64
+	dash      []float64
+	font      string
+	align     string
+}
+
+// Returns a new SVG renderer, for a document of the given size in pixels
+func newSVGRenderer(w float64, h float64) *svgRenderer {
+	return &svgRenderer{w: w, h: h, fill: "black", stroke: "black", lineWidth: 1, font: "10px sans-serif"}
+}
+
+// Begins a new path
+func (s *svgRenderer) BeginPath() {
+	s.path.Reset()
+}
+
+// Returns the finished SVG document
+func (s *svgRenderer) Bytes() []byte {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %s %s">`+"\n",
+		svgNum(s.w), svgNum(s.h), svgNum(s.w), svgNum(s.h))
+	b.Write(s.body.Bytes())
+	b.WriteString("</svg>\n")
+	return b.Bytes()
+}
+
+// Closes the current sub-path, back to its starting point
+func (s *svgRenderer) ClosePath() {
+	s.path.WriteString("Z ")
+}
+
+// Adds a whole ellipse to the current path, as two half ellipse arcs
+func (s *svgRenderer) Ellipse(x float64, y float64, radiusX float64, radiusY float64) {
+	rx, ry := svgNum(radiusX), svgNum(radiusY)
+	fmt.Fprintf(&s.path, "M %s %s A %s %s 0 1 0 %s %s A %s %s 0 1 0 %s %s Z ", svgNum(x+radiusX), svgNum(y), rx, ry,
+		svgNum(x-radiusX), svgNum(y), rx, ry, svgNum(x+radiusX), svgNum(y))
+}
+
+// Fills the current path with the fill style
+func (s *svgRenderer) Fill() {
+	fmt.Fprintf(&s.body, `<path d="%s" fill="%s" stroke="none"/>`+"\n", strings.TrimSpace(s.path.String()),
+		svgEscape(s.fill))
+}
+
+// Fills a rectangle with the fill style
+func (s *svgRenderer) FillRect(x float64, y float64, w float64, h float64) {
+	fmt.Fprintf(&s.body, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s" stroke="none"/>`+"\n", svgNum(x),
+		svgNum(y), svgNum(w), svgNum(h), svgEscape(s.fill))
+}
+
+// Draws text with the fill style, font, and text alignment.  The Y co-ordinate is the text baseline, as on the canvas
+func (s *svgRenderer) FillText(text string, x float64, y float64) {
+	anchor := "start"
+	switch s.align {
+	case "center":
+		anchor = "middle"
+	case "right", "end":
+		anchor = "end"
+	}
+	fmt.Fprintf(&s.body, `<text x="%s" y="%s" fill="%s" style="font: %s; white-space: pre" text-anchor="%s">%s</text>`+
+		"\n", svgNum(x), svgNum(y), svgEscape(s.fill), svgEscape(s.font), anchor, svgEscape(text))
+}
+
+// Adds a straight line to the current path
+func (s *svgRenderer) LineTo(x float64, y float64) {
+	fmt.Fprintf(&s.path, "L %s %s ", svgNum(x), svgNum(y))
+}
+
+// Starts a new sub-path at the given point
+func (s *svgRenderer) MoveTo(x float64, y float64) {
+	fmt.Fprintf(&s.path, "M %s %s ", svgNum(x), svgNum(y))
+}
+
+// Sets the colour used for fills and text
+func (s *svgRenderer) SetFillStyle(colour string) {
+	s.fill = colour
+}
+
+// Sets the font used for text
+func (s *svgRenderer) SetFont(font string) {
+	s.font = font
+}
+
+// Sets the dash pattern for lines.  An empty pattern gives solid lines
+func (s *svgRenderer) SetLineDash(dash []float64) {
+	s.dash = append([]float64(nil), dash...)
+}
+
+// Sets the width of lines
+func (s *svgRenderer) SetLineWidth(w float64) {
+	s.lineWidth = w
+}
+
+// Sets the colour used for lines
+func (s *svgRenderer) SetStrokeStyle(colour string) {
+	s.stroke = colour
+}
+
+// Sets the alignment of text, relative to the point it's drawn at
+func (s *svgRenderer) SetTextAlign(align string) {
+	s.align = align
+}
+
+// Draws the current path with the stroke style
+func (s *svgRenderer) Stroke() {
+	fmt.Fprintf(&s.body, `<path d="%s" fill="none" %s/>`+"\n", strings.TrimSpace(s.path.String()), s.strokeAttrs())
+}
+
+// Draws the outline of a rectangle with the stroke style
+func (s *svgRenderer) StrokeRect(x float64, y float64, w float64, h float64) {
+	fmt.Fprintf(&s.body, `<rect x="%s" y="%s" width="%s" height="%s" fill="none" %s/>`+"\n", svgNum(x), svgNum(y),
+		svgNum(w), svgNum(h), s.strokeAttrs())
+}
+
+// Returns the attributes for the current stroke style, line width, and dash pattern
+func (s *svgRenderer) strokeAttrs() string {
+	a := fmt.Sprintf(`stroke="%s" stroke-width="%s"`, svgEscape(s.stroke), svgNum(s.lineWidth))
+	if len(s.dash) > 0 {
+		d := make([]string, len(s.dash))
+		for i, v := range s.dash {
+			d[i] = svgNum(v)
+		}
+		a += fmt.Sprintf(` stroke-dasharray="%s"`, strings.Join(d, " "))
+	}
+	return a
+}
+
+// Draws a scene snapshot at the given size, returning it as an SVG document
+func renderSVG(sc *scene, w float64, h float64) []byte {
+	s := newSVGRenderer(w, h)
+	drawScene(s, sc, w, h, INFOEXPORT)
+	return s.Bytes()
+}
+
+// Returns text escaped for use in SVG (XML) attributes and elements
+func svgEscape(t string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(t))
+	return b.String()
+}
+
+// Returns a number formatted for SVG, to two decimal places without trailing zeros.  SVG has no way to give infinite
+// or undefined numbers, so they become 0
+func svgNum(v float64) string {
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return "0"
+	}
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// The model axes the turntable can rotate about
+type Axis int
+
+const (
+	XAXIS Axis = iota
+	YAXIS
+	ZAXIS
+)
+
+const (
+	// The initial turntable speed, and the limits it can be adjusted between, in degrees per second
+	turntableDefaultSpeed = 30
+	turntableMinSpeed     = 2
+	turntableMaxSpeed     = 360
+
+	// Each speed adjustment multiplies or divides the speed by this much
+	turntableSpeedStep = 1.5
+
+	// The turntable stays paused for this many milliseconds after the user last interacted with the graph
+	turntableIdleDelay = 3000
+
+	// The longest gap between frames (in milliseconds) the turntable will rotate across, so the model doesn't jump
+	// after the browser has stopped drawing frames for a while (eg when the tab is hidden)
+	turntableMaxStep = 100
+)
+
+var (
+	// The turntable state.  These are protected by sceneLock
+	turntableOn     bool
+	turntableAxis   = ZAXIS
+	turntableSpeed  = float64(turntableDefaultSpeed)
+	lastInteraction = math.Inf(-1) // Frame time the user last interacted with the graph
+
+	axisNames = map[Axis]string{XAXIS: "X", YAXIS: "Y", ZAXIS: "Z"}
+)
+
+// Changes the turntable speed by the given factor, keeping it within the speed limits
+func changeTurntableSpeed(factor float64) {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	turntableSpeed = math.Max(turntableMinSpeed, math.Min(turntableMaxSpeed, turntableSpeed*factor))
+	turntableStatus()
+}
+
+// Pauses the turntable while the user interacts with the graph.  It carries on by itself once the user has left the
+// graph alone for a while
+func pauseTurntable() {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	lastInteraction = clock.Now()
+}
+
+// Changes the model axis the turntable rotates about
+func setTurntableAxis(a Axis) {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	turntableAxis = a
+	turntableStatus()
+}
+
+// Rotates the model by however far the turntable turns between frames, given the milliseconds since the last frame.
+// The rotation is about the model's own axis through the pivot point, rather than the screen's, so it spins in place
+// like a turntable.  It doesn't go through the scheduler (so it doesn't fill the undo history), and it holds off while
+// anything else is changing the view
+func stepTurntable(now float64, dt float64) {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	if !turntableOn || anim != nil || len(pending) > 0 || player != nil || arcballActive || panActive ||
+		zoomBoxActive || spinSpeed > 0 || now-lastInteraction < turntableIdleDelay {
+		return
+	}
+	degrees := turntableSpeed * math.Min(dt, turntableMaxStep) / 1000
+	var r matrix
+	switch turntableAxis {
+	case XAXIS:
+		r = rotateAroundX(identityMatrix, degrees)
+	case YAXIS:
+		r = rotateAroundY(identityMatrix, degrees)
+	default:
+		r = rotateAroundZ(identityMatrix, degrees)
+	}
+	viewMatrix = matrixMult(viewMatrix, aroundPoint(r, pivotPoint))
+	publishScene()
+}
+
+// Turns the turntable on or off
+func toggleTurntable() {
+	sceneLock.Lock()
+	defer sceneLock.Unlock()
+	turntableOn = !turntableOn
+	lastInteraction = math.Inf(-1)
+	if !turntableOn {
+		opText = "Turntable off."
+		publishScene()
+		return
+	}
+	turntableStatus()
+}
+
+// Shows the turntable settings in the operation text.  The scene lock must be held by the caller
+func turntableStatus() {
+	opText = fmt.Sprintf("Turntable %.0f°/s about %s.", turntableSpeed, axisNames[turntableAxis])
+	publishScene()
+}
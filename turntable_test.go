@@ -0,0 +1,40 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTurntableResumesAfterSpin(t *testing.T) {
+	sceneLock.Lock()
+	viewMatrix = identityMatrix
+	anim, pending, player = nil, nil, nil
+	arcballActive, panActive, zoomBoxActive = false, false, false
+	turntableOn, lastInteraction = true, math.Inf(-1)
+	spinAxis, spinSpeed = vector{Z: 1}, 0.01
+	sceneLock.Unlock()
+	defer func() {
+		sceneLock.Lock()
+		defer sceneLock.Unlock()
+		turntableOn, spinSpeed = false, 0
+	}()
+
+	// Let the spin from an arcball flick die away
+	sceneLock.Lock()
+	for i := 0; i < 10000; i++ {
+		if arcballSpin(16) == nil {
+			break
+		}
+	}
+	speed := spinSpeed
+	sceneLock.Unlock()
+	if speed != 0 {
+		t.Fatalf("spin speed is %v after the spin stopped", speed)
+	}
+
+	// Then the turntable takes over again
+	stepTurntable(0, 16)
+	if v := currentScene().view; matrixNear(v, identityMatrix) {
+		t.Error("turntable didn't turn after the spin stopped")
+	}
+}
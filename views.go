@@ -0,0 +1,40 @@
+package main
+
+import "math"
+
+// The canonical camera orientations
+type PresetView int
+
+const (
+	TOPVIEW   PresetView = iota // Looking down onto the XY plane
+	FRONTVIEW                   // Looking at the XZ plane, with Z up
+	SIDEVIEW                    // Looking at the YZ plane, with Z up
+	ISOVIEW                     // Isometric, looking back towards the origin from (1, 1, 1), with Z up
+)
+
+// Returns the rotation matrix for a preset view
+func presetRotation(v PresetView) matrix {
+	m := identityMatrix
+	switch v {
+	case FRONTVIEW:
+		m = rotateAroundX(m, -90)
+	case SIDEVIEW:
+		m = rotateAroundZ(m, -90)
+		m = rotateAroundX(m, -90)
+	case ISOVIEW:
+		m = rotateAroundZ(m, -135)
+		m = rotateAroundX(m, -math.Atan(math.Sqrt2)*180/math.Pi)
+	}
+	return m
+}
+
+// Animates to a preset view.  The target is an absolute orientation, so it's the same no matter how the graph is
+// currently rotated.  The current zoom level and pan are kept
+func showPresetView(v PresetView) {
+	sceneLock.Lock()
+	s := zoomLevel()
+	m := scale(presetRotation(v), s, s, s)
+	m = translate(m, viewMatrix[3], viewMatrix[7], viewMatrix[11])
+	sceneLock.Unlock()
+	submitOperation(viewOp(m, false))
+}
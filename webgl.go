@@ -0,0 +1,307 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"syscall/js"
+)
+
+// The vertex shader.  Points are moved into view space by the view matrix, then onto the canvas the same way
+// drawScene places them, with Y pointing up.  Browsers only reliably draw WebGL lines one pixel wide, so the graph
+// lines are drawn as triangles instead.  Each of their vertices comes with the other end of its line segment and which
+// side of the line it's on, and is moved out sideways by half the line width
+const webglVertexShader = `
+attribute vec3 position;
+attribute vec3 other;
+attribute float side;
+uniform mat4 view;
+uniform vec2 scale;
+uniform vec2 offset;
+uniform vec2 pixel;
+uniform float pointSize;
+uniform float halfWidth;
+void main() {
+	vec2 p = (view * vec4(position, 1.0)).xy * scale + offset;
+	vec2 d = ((view * vec4(other, 1.0)).xy * scale + offset - p) / pixel;
+	if (side != 0.0 && length(d) > 0.0) {
+		p += vec2(-d.y, d.x) / length(d) * side * halfWidth * pixel;
+	}
+	gl_Position = vec4(p, 0.0, 1.0);
+	gl_PointSize = pointSize;
+}
+`
+
+// The fragment shader.  Points are drawn as round dots rather than squares
+const webglFragmentShader = `
+precision mediump float;
+uniform vec4 colour;
+uniform bool roundPoints;
+void main() {
+	if (roundPoints && length(gl_PointCoord - vec2(0.5)) > 0.5) {
+		discard;
+	}
+	gl_FragColor = colour;
+}
+`
+
+// Draws the geometry of a scene with WebGL.  Each object's points are uploaded to the GPU once, the first time the
+// object is drawn, and the view matrix is applied by the vertex shader.  So a frame only needs a handful of javascript
+// calls per object, no matter how many points it has
+type webglRenderer struct {
+	gl                                 js.Value
+	position, other, side              int
+	view, scale, offset, pixel, colour js.Value
+	pointSize, roundPoints, halfWidth  js.Value
+	meshes                             map[meshKey]*mesh
+	arrayBuffer, staticDraw, float     js.Value
+	triangles, lines, points           js.Value
+	colourBufferBit                    js.Value
+}
+
+// Identifies the points of an object.  The world space objects are replaced rather than changed, so a new list of
+// points means the object needs uploading again
+type meshKey struct {
+	p *Point
+	n int
+}
+
+// The vertex buffers for an object.  The graph line is drawn from the strip buffers, which hold two triangles for each
+// line segment, along with the other end of the segment and the side of the line for each of their vertices
+type mesh struct {
+	tris, lines, pts             js.Value
+	strip, stripOther, stripSide js.Value
+	nTris, nLines, nPts, nStrip  int
+}
+
+// Returns a new WebGL renderer for a canvas, or an error if the browser doesn't support WebGL
+func newWebGLRenderer(c js.Value) (*webglRenderer, error) {
+	gl := c.Call("getContext", "webgl")
+	if gl == js.Null() || gl == js.Undefined() {
+		gl = c.Call("getContext", "experimental-webgl")
+	}
+	if gl == js.Null() || gl == js.Undefined() {
+		return nil, fmt.Errorf("WebGL isn't available")
+	}
+
+	// Compile and link the shaders
+	prog := gl.Call("createProgram")
+	for _, s := range []struct {
+		kind   string
+		source string
+	}{{"VERTEX_SHADER", webglVertexShader}, {"FRAGMENT_SHADER", webglFragmentShader}} {
+		sh := gl.Call("createShader", gl.Get(s.kind))
+		jsCall(gl, "shaderSource", sh, s.source)
+		jsCall(gl, "compileShader", sh)
+		if !gl.Call("getShaderParameter", sh, gl.Get("COMPILE_STATUS")).Bool() {
+			return nil, fmt.Errorf("couldn't compile the shader: %s", gl.Call("getShaderInfoLog", sh).String())
+		}
+		jsCall(gl, "attachShader", prog, sh)
+	}
+	jsCall(gl, "linkProgram", prog)
+	if !gl.Call("getProgramParameter", prog, gl.Get("LINK_STATUS")).Bool() {
+		return nil, fmt.Errorf("couldn't link the shaders: %s", gl.Call("getProgramInfoLog", prog).String())
+	}
+	jsCall(gl, "useProgram", prog)
+
+	g := &webglRenderer{
+		gl:              gl,
+		position:        gl.Call("getAttribLocation", prog, "position").Int(),
+		other:           gl.Call("getAttribLocation", prog, "other").Int(),
+		side:            gl.Call("getAttribLocation", prog, "side").Int(),
+		view:            gl.Call("getUniformLocation", prog, "view"),
+		scale:           gl.Call("getUniformLocation", prog, "scale"),
+		offset:          gl.Call("getUniformLocation", prog, "offset"),
+		pixel:           gl.Call("getUniformLocation", prog, "pixel"),
+		colour:          gl.Call("getUniformLocation", prog, "colour"),
+		pointSize:       gl.Call("getUniformLocation", prog, "pointSize"),
+		roundPoints:     gl.Call("getUniformLocation", prog, "roundPoints"),
+		halfWidth:       gl.Call("getUniformLocation", prog, "halfWidth"),
+		meshes:          make(map[meshKey]*mesh),
+		arrayBuffer:     gl.Get("ARRAY_BUFFER"),
+		staticDraw:      gl.Get("STATIC_DRAW"),
+		float:           gl.Get("FLOAT"),
+		triangles:       gl.Get("TRIANGLES"),
+		lines:           gl.Get("LINES"),
+		points:          gl.Get("POINTS"),
+		colourBufferBit: gl.Get("COLOR_BUFFER_BIT"),
+	}
+	jsCall(gl, "enableVertexAttribArray", g.position)
+
+	// Only the graph lines have vertices moved sideways, so everything else is drawn with the side left at zero
+	jsCall(gl, "vertexAttrib1f", g.side, 0)
+	jsCall(gl, "vertexAttrib3f", g.other, 0, 0, 0)
+
+	// The colours are premultiplied by their alpha, as they come from parseColour
+	jsCall(gl, "enable", gl.Get("BLEND"))
+	jsCall(gl, "blendFunc", gl.Get("ONE"), gl.Get("ONE_MINUS_SRC_ALPHA"))
+	return g, nil
+}
+
+// Draws the geometry of a scene snapshot at the given size in CSS pixels, matching drawGeometry.  The canvas has the
+// given number of device pixels per CSS pixel
+func (g *webglRenderer) draw(sc *scene, w float64, h float64, ratio float64) {
+	gl := g.gl
+	jsCall(gl, "viewport", 0, 0, math.Round(w*ratio), math.Round(h*ratio))
+	jsCall(gl, "clearColor", 1, 1, 1, 1)
+	jsCall(gl, "clear", g.colourBufferBit)
+
+	// Pass the view matrix to the shader in column major order, as WebGL needs
+	var m [16]float32
+	for r := 0; r < 4; r++ {
+		for c := 0; c < 4; c++ {
+			m[c*4+r] = float32(sc.view[r*4+c])
+		}
+	}
+	a := js.TypedArrayOf(m[:])
+	jsCall(gl, "uniformMatrix4fv", g.view, false, a)
+	a.Release()
+
+	// Work out where the centre of the graph area is in clip space, and how big a world space unit is
+	step := pixelsPerUnitAt(w, h)
+	centerX := w * 0.75 / 2
+	centerY := (h - 1) / 2
+	jsCall(gl, "uniform2f", g.scale, step*2/w, step*2/h)
+	jsCall(gl, "uniform2f", g.offset, centerX*2/w-1, 1-centerY*2/h)
+	jsCall(gl, "uniform2f", g.pixel, 2/w, 2/h)
+	jsCall(gl, "uniform1f", g.pointSize, 1)
+	jsCall(gl, "uniform1i", g.roundPoints, 0)
+
+	// Draw the surfaces and edges, uploading any objects which haven't been seen before
+	seen := make(map[meshKey]bool, len(sc.objects))
+	meshes := make([]*mesh, len(sc.objects))
+	for i, o := range sc.objects {
+		if len(o.P) == 0 {
+			continue
+		}
+		k := meshKey{p: &o.P[0], n: len(o.P)}
+		seen[k] = true
+		if g.meshes[k] == nil {
+			g.meshes[k] = g.upload(o)
+		}
+		meshes[i] = g.meshes[k]
+		g.setColour(o.C)
+		g.drawBuffer(meshes[i].tris, g.triangles, meshes[i].nTris)
+		g.drawBuffer(meshes[i].lines, g.lines, meshes[i].nLines)
+	}
+
+	// Draw the graph and derivatives two pixels wide, with a dot for each point.  The canvas fills its dots in black,
+	// then strokes them two pixels wide in the graph colour, which covers the black.  So they're drawn here as four
+	// pixel wide dots in the graph colour
+	for i, o := range sc.objects {
+		if meshes[i] == nil || !isGraph(o) {
+			continue
+		}
+		g.setColour(o.C)
+		g.drawStrip(meshes[i])
+		jsCall(gl, "uniform1f", g.pointSize, 4*ratio)
+		jsCall(gl, "uniform1i", g.roundPoints, 1)
+		g.drawBuffer(meshes[i].pts, g.points, meshes[i].nPts)
+		jsCall(gl, "uniform1f", g.pointSize, 1)
+		jsCall(gl, "uniform1i", g.roundPoints, 0)
+	}
+
+	// Throw away the buffers of objects which aren't in the scene any more
+	for k, old := range g.meshes {
+		if !seen[k] {
+			for _, b := range []js.Value{old.tris, old.lines, old.pts, old.strip, old.stripOther, old.stripSide} {
+				if b != js.Null() {
+					jsCall(gl, "deleteBuffer", b)
+				}
+			}
+			delete(g.meshes, k)
+		}
+	}
+}
+
+// Draws the vertices in a buffer with the given WebGL drawing mode
+func (g *webglRenderer) drawBuffer(b js.Value, mode js.Value, n int) {
+	if n == 0 {
+		return
+	}
+	jsCall(g.gl, "bindBuffer", g.arrayBuffer, b)
+	jsCall(g.gl, "vertexAttribPointer", g.position, 3, g.float, false, 0, 0)
+	jsCall(g.gl, "drawArrays", mode, 0, n)
+}
+
+// Draws the graph line of a mesh two pixels wide, from its strip buffers
+func (g *webglRenderer) drawStrip(m *mesh) {
+	if m.nStrip == 0 {
+		return
+	}
+	gl := g.gl
+	jsCall(gl, "uniform1f", g.halfWidth, 1)
+	jsCall(gl, "enableVertexAttribArray", g.other)
+	jsCall(gl, "enableVertexAttribArray", g.side)
+	jsCall(gl, "bindBuffer", g.arrayBuffer, m.stripOther)
+	jsCall(gl, "vertexAttribPointer", g.other, 3, g.float, false, 0, 0)
+	jsCall(gl, "bindBuffer", g.arrayBuffer, m.stripSide)
+	jsCall(gl, "vertexAttribPointer", g.side, 1, g.float, false, 0, 0)
+	g.drawBuffer(m.strip, g.triangles, m.nStrip)
+	jsCall(gl, "disableVertexAttribArray", g.other)
+	jsCall(gl, "disableVertexAttribArray", g.side)
+}
+
+// Sets the colour the following drawing is done in, from a CSS colour string
+func (g *webglRenderer) setColour(c string) {
+	col := parseColour(c)
+	jsCall(g.gl, "uniform4f", g.colour, float64(col.R)/255, float64(col.G)/255, float64(col.B)/255, float64(col.A)/255)
+}
+
+// Uploads the points of an object to the GPU, as vertex buffers: triangles for the surfaces, pairs of points for the
+// edges, all of the points in order for the graph dots, and the strips the graph lines are drawn with
+func (g *webglRenderer) upload(o Object) *mesh {
+	var tris, lines, pts, strip, stripOther, stripSide []float32
+	add := func(v []float32, p Point) []float32 {
+		return append(v, float32(p.X), float32(p.Y), float32(p.Z))
+	}
+
+	// Split each surface into a fan of triangles
+	for _, s := range o.S {
+		for i := 2; i < len(s); i++ {
+			tris = add(add(add(tris, o.P[s[0]]), o.P[s[i-1]]), o.P[s[i]])
+		}
+	}
+	for _, e := range o.E {
+		lines = add(add(lines, o.P[e[0]]), o.P[e[1]])
+	}
+	if isGraph(o) {
+		for _, p := range o.P {
+			pts = add(pts, p)
+		}
+
+		// Two triangles for each line segment.  The vertices at the far end see the segment the other way around, so
+		// their sides are swapped to keep both edges of the strip on the same side
+		for i := 1; i < len(o.P); i++ {
+			a, b := o.P[i-1], o.P[i]
+			for _, v := range []struct {
+				p, q Point
+				side float32
+			}{{a, b, 1}, {a, b, -1}, {b, a, -1}, {b, a, -1}, {a, b, -1}, {b, a, 1}} {
+				strip = add(strip, v.p)
+				stripOther = add(stripOther, v.q)
+				stripSide = append(stripSide, v.side)
+			}
+		}
+	}
+	return &mesh{
+		tris: g.uploadBuffer(tris), lines: g.uploadBuffer(lines), pts: g.uploadBuffer(pts),
+		strip: g.uploadBuffer(strip), stripOther: g.uploadBuffer(stripOther), stripSide: g.uploadBuffer(stripSide),
+		nTris: len(tris) / 3, nLines: len(lines) / 3, nPts: len(pts) / 3, nStrip: len(strip) / 3,
+	}
+}
+
+// Uploads vertex data to a new buffer on the GPU.  Returns null if there's no data
+func (g *webglRenderer) uploadBuffer(v []float32) js.Value {
+	if len(v) == 0 {
+		return js.Null()
+	}
+	b := jsCall(g.gl, "createBuffer")
+	jsCall(g.gl, "bindBuffer", g.arrayBuffer, b)
+	a := js.TypedArrayOf(v)
+	jsCall(g.gl, "bufferData", g.arrayBuffer, a, g.staticDraw)
+	a.Release()
+	return b
+}